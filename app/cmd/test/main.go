@@ -2,13 +2,28 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"social-app/config"
 	"social-app/internal/domain"
 	"social-app/internal/repository"
+	"social-app/internal/router"
+	"social-app/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -27,18 +42,66 @@ func main() {
 	fmt.Println("Redis connected:", pong)
 
 	// Initialize user status repository
-	userStatusRepo := repository.NewRedisUserStatusRepository(redisClient)
+	policy, err := config.NewPresencePolicy()
+	if err != nil {
+		log.Fatal("Failed to load presence policy:", err)
+	}
+	userStatusRepo := repository.NewRedisUserStatusRepository(redisClient, policy, config.NewStatusHistoryRetention())
+	roomRepo := repository.NewRedisRoomRepository(redisClient)
+	contactsRepo := repository.NewRedisContactsRepository(redisClient)
+
+	// Test users get IDs in the configured scheme, normalized like the API does
+	scheme := config.NewUserIDScheme()
+	ids, err := services.NewUserIDValidator(scheme)
+	if err != nil {
+		log.Fatal("Failed to configure user IDs:", err)
+	}
+	id := func(n int) string {
+		return testUserID(ids, scheme, n)
+	}
+
+	// Service level tests go through validation and presence derivation like the API
+	userStatusService := services.NewUserStatusService(userStatusRepo, contactsRepo, policy, ids, config.NewBulkMaxUserIDs())
+	api := router.Services{
+		UserStatus:    userStatusService,
+		Rooms:         services.NewRoomService(roomRepo, userStatusService),
+		Typing:        services.NewTypingService(repository.NewRedisTypingRepository(redisClient), userStatusService),
+		PresenceStats: services.NewPresenceStatsService(repository.NewRedisPresenceStatsRepository(redisClient)),
+		Contacts:      services.NewContactsService(contactsRepo, userStatusService),
+	}
 
 	// Run comprehensive tests
-	runUserStatusTests(userStatusRepo)
+	runUserStatusTests(userStatusRepo, policy, id)
+	runConcurrencyTests(redisClient, userStatusRepo, policy, id)
+	runQuietHoursTests()
+	runActivityTests(userStatusService, policy, id)
+	runVisibilityTests(userStatusService, id)
+	runLastSeenPrivacyTests(userStatusService, api.Contacts, id)
+	runBulkStatusRequestTests(api, id)
+	runCustomStatusTests(userStatusService, id)
+	runDNDTests(userStatusService, id)
+	runExpiryTests(redisClient, userStatusRepo, id)
+	runStatusHistoryTests(redisClient, userStatusService, id)
+	runManualStatusTests(redisClient, userStatusService, id)
+	runPresencePolicyTests(redisClient, userStatusService, policy, id)
+	runHeartbeatIntervalTests(redisClient, userStatusService, policy, id)
+	runStatusTypeTests(redisClient, userStatusService, id)
+	runUserIDSchemeTests(redisClient, userStatusRepo, contactsRepo, policy)
+	runBulkTransitionTests(redisClient, userStatusService, policy, id)
+	runBulkPublicStatusTests(api, id)
+	runRoomPresenceTests(redisClient, api, id)
+	runTypingTests(redisClient, api, id)
+	runPresenceStatsTests(redisClient, userStatusRepo, api, id)
+	runOnlineContactsTests(redisClient, api, id)
+	runBulkHeartbeatTests(redisClient, api, ids, policy, id)
 
 	fmt.Println("\n=== All tests completed ===")
 }
 
-func runUserStatusTests(repo domain.UserStatusRepository) {
+func runUserStatusTests(repo domain.UserStatusRepository, policy domain.PresencePolicy, id func(int) string) {
 	// Test 1: Set user online
 	fmt.Println("\n1. Setting user 123 online...")
-	err := repo.SetUserStatus("123", domain.StatusOnline, domain.OnlineTTL)
+	err := repo.SetUserStatus(id(123), domain.StatusOnline, policy.StatusTTL(domain.StatusOnline))
 	if err != nil {
 		log.Printf("❌ Error setting user online: %v", err)
 	} else {
@@ -47,7 +110,7 @@ func runUserStatusTests(repo domain.UserStatusRepository) {
 
 	// Test 2: Get user status
 	fmt.Println("\n2. Getting user 123 status...")
-	status, err := repo.GetUserStatus("123")
+	status, err := repo.GetUserStatus(id(123))
 	if err != nil {
 		log.Printf("❌ Error getting user status: %v", err)
 	} else {
@@ -56,14 +119,14 @@ func runUserStatusTests(repo domain.UserStatusRepository) {
 
 	// Test 3: Set multiple users with different statuses
 	fmt.Println("\n3. Setting multiple users status...")
-	repo.SetUserStatus("456", domain.StatusOnline, domain.OnlineTTL)
-	repo.SetUserStatus("789", domain.StatusAway, domain.AwayTTL)
-	repo.SetUserStatus("101", domain.StatusOffline, domain.OfflineTTL)
+	repo.SetUserStatus(id(456), domain.StatusOnline, policy.StatusTTL(domain.StatusOnline))
+	repo.SetUserStatus(id(789), domain.StatusAway, policy.StatusTTL(domain.StatusAway))
+	repo.SetUserStatus(id(101), domain.StatusOffline, policy.StatusTTL(domain.StatusOffline))
 	fmt.Println("✅ Set user 456 online, user 789 away, user 101 offline")
 
 	// Test 4: Get multiple users status
 	fmt.Println("\n4. Getting multiple users status...")
-	userIDs := []string{"123", "456", "789", "101", "999"}
+	userIDs := []string{id(123), id(456), id(789), id(101), id(999)}
 	statuses, err := repo.GetMultipleUserStatus(userIDs)
 	if err != nil {
 		log.Printf("❌ Error getting multiple user status: %v", err)
@@ -76,7 +139,7 @@ func runUserStatusTests(repo domain.UserStatusRepository) {
 
 	// Test 5: Refresh TTL (heartbeat)
 	fmt.Println("\n5. Refreshing user 123 TTL (heartbeat)...")
-	err = repo.RefreshUserStatusTTL("123", domain.OnlineTTL)
+	err = repo.RefreshUserStatusTTL(id(123), policy.StatusTTL(domain.StatusOnline))
 	if err != nil {
 		log.Printf("❌ Error refreshing TTL: %v", err)
 	} else {
@@ -87,7 +150,7 @@ func runUserStatusTests(repo domain.UserStatusRepository) {
 	fmt.Println("\n6. Waiting 5 seconds to test TTL...")
 	time.Sleep(5 * time.Second)
 
-	status, err = repo.GetUserStatus("123")
+	status, err = repo.GetUserStatus(id(123))
 	if err != nil {
 		log.Printf("❌ Error getting user status after wait: %v", err)
 	} else {
@@ -102,4 +165,1714 @@ func runUserStatusTests(repo domain.UserStatusRepository) {
 	} else {
 		fmt.Printf("✅ Non-existent user status: %s\n", status)
 	}
+
+	// Test 8: Multi-device sessions aggregate by precedence
+	fmt.Println("\n8. Testing multi-device session aggregation for user 202...")
+	repo.SetSessionStatus(id(202), domain.SessionPresence{SessionID: "desktop-1", DeviceType: domain.DeviceDesktop, Status: domain.StatusOnline}, policy.StatusTTL(domain.StatusOnline))
+	repo.SetSessionStatus(id(202), domain.SessionPresence{SessionID: "phone-1", DeviceType: domain.DeviceMobile, Status: domain.StatusAway}, policy.StatusTTL(domain.StatusAway))
+	status, err = repo.GetUserStatus(id(202))
+	if err != nil {
+		log.Printf("❌ Error getting aggregated status: %v", err)
+	} else if status != domain.StatusOnline {
+		log.Printf("❌ Expected aggregated status online, got %s", status)
+	} else {
+		fmt.Printf("✅ User 202 aggregated status: %s\n", status)
+	}
+
+	// Heartbeat on the phone session must not affect the desktop session
+	_, _, err = repo.RefreshSessionTTL(id(202), domain.ClientSession{SessionID: "phone-1"}, domain.StatusOnline, 0, policy.StatusTTL(domain.StatusOnline))
+	if err != nil {
+		log.Printf("❌ Error sending session heartbeat: %v", err)
+	}
+	sessions, err := repo.GetUserSessions(id(202))
+	if err != nil {
+		log.Printf("❌ Error getting sessions: %v", err)
+	} else {
+		fmt.Printf("✅ User 202 has %d live sessions:\n", len(sessions))
+		for _, session := range sessions {
+			fmt.Printf("   - %s (%s): %s\n", session.SessionID, session.DeviceType, session.Status)
+		}
+	}
+
+	// Test 9: Last seen survives status expiry
+	fmt.Println("\n9. Getting last seen for users 202 and 999...")
+	lastSeen, err := repo.GetMultipleLastSeen([]string{id(202), id(999)})
+	if err != nil {
+		log.Printf("❌ Error getting last seen: %v", err)
+	} else if lastSeen[id(202)] == nil || lastSeen[id(999)] != nil {
+		log.Printf("❌ Unexpected last seen values: %v", lastSeen)
+	} else {
+		fmt.Printf("✅ User 202 last seen at %s, user 999 never seen\n", lastSeen[id(202)].Format(time.RFC3339))
+	}
+}
+
+func runConcurrencyTests(client *redis.Client, repo domain.UserStatusRepository, policy domain.PresencePolicy, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 10: Concurrent heartbeats on different sessions lose no session
+	fmt.Println("\n10. Sending 100 concurrent heartbeats on 20 sessions of user 303...")
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			session := domain.ClientSession{SessionID: fmt.Sprintf("tab-%d", i%20), DeviceType: domain.DeviceWeb}
+			if _, _, err := repo.RefreshSessionTTL(id(303), session, domain.StatusOnline, 0, policy.StatusTTL(domain.StatusOnline)); err != nil {
+				log.Printf("❌ Error sending heartbeat: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	sessions, err := repo.GetUserSessions(id(303))
+	if err != nil {
+		log.Printf("❌ Error getting sessions: %v", err)
+	} else if len(sessions) != 20 {
+		log.Printf("❌ Lost updates: expected 20 sessions, got %d", len(sessions))
+	} else {
+		fmt.Println("✅ All 20 sessions recorded")
+	}
+
+	// Test 11: Explicit status racing an expiry transition is never overwritten
+	fmt.Println("\n11. Racing expiry transitions against explicit online for user 404...")
+	lost := 0
+	for round := 0; round < 50; round++ {
+		client.Del(ctx, domain.GetUserStatusKey(id(404)), domain.GetUserSessionsKey(id(404)), domain.GetUserSessionKey(id(404), domain.DefaultSessionID))
+		if err := client.Set(ctx, "user:last_status:"+id(404), string(domain.StatusOnline), time.Hour).Err(); err != nil {
+			log.Printf("❌ Error simulating the expiry: %v", err)
+			return
+		}
+
+		var race sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			race.Add(1)
+			go func() {
+				defer race.Done()
+				repo.GetUserStatus(id(404)) // Triggers online→away transition
+			}()
+		}
+		race.Add(1)
+		go func() {
+			defer race.Done()
+			repo.SetUserStatus(id(404), domain.StatusOnline, policy.StatusTTL(domain.StatusOnline))
+		}()
+		race.Wait()
+
+		if status, _ := repo.GetUserStatus(id(404)); status != domain.StatusOnline {
+			lost++
+		}
+	}
+	if lost > 0 {
+		log.Printf("❌ Explicit status lost to stale transition in %d/50 rounds", lost)
+	} else {
+		fmt.Println("✅ Explicit online status survived all 50 races")
+	}
+
+	// Test 12: Two devices setting status from the same version, only one wins
+	fmt.Println("\n12. Two devices setting status of user 505 with the same If-Match version...")
+	_, version, err := repo.GetUserStatusVersion(id(505))
+	if err != nil {
+		log.Printf("❌ Error getting status version: %v", err)
+		return
+	}
+	var writers sync.WaitGroup
+	results := make(chan error, 2)
+	for _, status := range []domain.Status{domain.StatusAway, domain.StatusInvisible} {
+		writers.Add(1)
+		go func(status domain.Status) {
+			defer writers.Done()
+			session := domain.SessionPresence{SessionID: "device-" + status.String(), Status: status, Source: domain.SourceManual}
+			_, err := repo.CompareAndSetSessionStatus(id(505), session, policy.StatusTTL(status), version)
+			results <- err
+		}(status)
+	}
+	writers.Wait()
+	close(results)
+
+	won, conflicts := 0, 0
+	for err := range results {
+		switch err {
+		case nil:
+			won++
+		case domain.ErrVersionConflict:
+			conflicts++
+		default:
+			log.Printf("❌ Error setting status: %v", err)
+		}
+	}
+	if won != 1 || conflicts != 1 {
+		log.Printf("❌ Expected one write and one conflict, got %d writes and %d conflicts", won, conflicts)
+	} else {
+		fmt.Println("✅ One write succeeded, the other got a version conflict")
+	}
+}
+
+func runQuietHoursTests() {
+	// Test 13: Quiet hours windows across midnight and DST changes
+	fmt.Println("\n13. Evaluating quiet hours windows (overnight and DST boundaries)...")
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		log.Printf("❌ Error loading time zone: %v", err)
+		return
+	}
+	schedule := func(days []string, start, end string) *domain.QuietHoursSchedule {
+		return &domain.QuietHoursSchedule{
+			TimeZone: newYork.String(),
+			Enabled:  true,
+			Windows:  []domain.QuietHoursWindow{{Days: days, Start: start, End: end}},
+		}
+	}
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, newYork)
+	}
+	utc := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	overnight := schedule([]string{"mon"}, "22:00", "07:00")
+	cases := []struct {
+		name     string
+		schedule *domain.QuietHoursSchedule
+		now      time.Time
+		want     *time.Time // nil = not quiet
+	}{
+		{"Monday 23:00, overnight window started today", overnight, at(time.March, 2, 23, 0), ptr(at(time.March, 3, 7, 0))},
+		{"Tuesday 06:59, overnight window started yesterday", overnight, at(time.March, 3, 6, 59), ptr(at(time.March, 3, 7, 0))},
+		{"Tuesday 07:00, window just ended", overnight, at(time.March, 3, 7, 0), nil},
+		{"Monday 02:00, no window started Sunday", overnight, at(time.March, 2, 2, 0), nil},
+		{"Sunday 23:00, window not scheduled on Sunday", overnight, at(time.March, 1, 23, 0), nil},
+		// Clocks jump from 02:00 to 03:00 on 2026-03-08: a 02:30 end ends at the jump
+		{"spring forward, end skipped", schedule([]string{"sun"}, "01:00", "02:30"), at(time.March, 8, 1, 45), ptr(utc(time.March, 8, 7, 0))},
+		// Clocks fall back from 02:00 to 01:00 on 2026-11-01: 01:30 happens twice
+		{"fall back, first 01:15", schedule([]string{"sat"}, "22:00", "01:30"), utc(time.November, 1, 5, 15), ptr(utc(time.November, 1, 5, 30))},
+		{"fall back, repeated 01:15 after the window ended", schedule([]string{"sat"}, "22:00", "01:30"), utc(time.November, 1, 6, 15), nil},
+		{"fall back, same-day window not entered again", schedule([]string{"sun"}, "01:00", "01:45"), utc(time.November, 1, 6, 10), nil},
+		{"fall back, same-day window in its first hour", schedule([]string{"sun"}, "01:00", "01:45"), utc(time.November, 1, 5, 10), ptr(utc(time.November, 1, 5, 45))},
+	}
+
+	var check checks
+	for _, c := range cases {
+		got := c.schedule.ActiveUntil(c.now)
+		check.expect((got == nil) == (c.want == nil) && (got == nil || got.Equal(*c.want)), "%s: expected quiet until %v, got %v", c.name, c.want, got)
+	}
+	check.summary("All %d quiet hours cases matched", len(cases))
+}
+
+func runActivityTests(service *services.UserStatusService, policy domain.PresencePolicy, id func(int) string) {
+	// Test 14: Client activity decides online vs away
+	fmt.Printf("\n14. Deriving online/away from heartbeat activity (idle away after %s)...\n", policy.IdleAwayThreshold)
+	idleAway := int64(policy.IdleAwayThreshold.Seconds())
+	cases := []struct {
+		name     string
+		activity *domain.HeartbeatActivity
+		want     domain.Status
+	}{
+		{"no activity reported", nil, domain.StatusOnline},
+		{"idle just below the threshold", &domain.HeartbeatActivity{IdleSeconds: idleAway - 1}, domain.StatusOnline},
+		{"idle at the threshold", &domain.HeartbeatActivity{IdleSeconds: idleAway}, domain.StatusAway},
+		{"idle but typing", &domain.HeartbeatActivity{IdleSeconds: idleAway, InputActive: ptr(true)}, domain.StatusOnline},
+		{"backgrounded", &domain.HeartbeatActivity{Foreground: ptr(false)}, domain.StatusAway},
+		{"foreground and recently active", &domain.HeartbeatActivity{IdleSeconds: 10, Foreground: ptr(true)}, domain.StatusOnline},
+	}
+
+	var check checks
+	for i, c := range cases {
+		result, err := service.SendHeartbeat(id(600+i), domain.ClientSession{SessionID: "activity"}, c.activity)
+		if err != nil {
+			check.failf("%s: error sending heartbeat: %v", c.name, err)
+		} else {
+			check.expect(result.Status == c.want, "%s: expected %s, got %s", c.name, c.want, result.Status)
+		}
+	}
+
+	// An away session comes back online as soon as the user is active again
+	steps := []struct {
+		activity *domain.HeartbeatActivity
+		want     domain.Status
+	}{
+		{&domain.HeartbeatActivity{IdleSeconds: idleAway}, domain.StatusAway},
+		{&domain.HeartbeatActivity{InputActive: ptr(true)}, domain.StatusOnline},
+	}
+	for _, step := range steps {
+		result, err := service.SendHeartbeat(id(650), domain.ClientSession{SessionID: "activity"}, step.activity)
+		if err != nil {
+			check.failf("Error sending heartbeat: %v", err)
+		} else {
+			check.expect(result.Status == step.want, "Away→online: expected %s, got %s", step.want, result.Status)
+		}
+	}
+
+	check.summary("All %d activity cases matched, away→online on activity", len(cases))
+}
+
+func runVisibilityTests(service *services.UserStatusService, id func(int) string) {
+	// Test 15: Visibility rules decide per viewer what the public status shows
+	fmt.Println("\n15. Evaluating visibility rules of user 700 per viewer...")
+	owner := id(700)
+	if err := service.SetUserStatus(owner, domain.ClientSession{}, domain.StatusOnline); err != nil {
+		log.Printf("❌ Error setting user online: %v", err)
+		return
+	}
+
+	rules := domain.VisibilityRules{
+		Default: domain.VisibilityVisible,
+		Rules: []domain.VisibilityRule{
+			{Name: "close friends", Visibility: domain.VisibilityVisible, Viewers: []string{id(701)}},
+			{Name: "coworkers", Visibility: domain.VisibilityOffline, Viewers: []string{id(701), id(702)}},
+			{Name: "blocked", Visibility: domain.VisibilityHidden, Viewers: []string{id(703)}},
+		},
+	}
+	type viewerCase struct {
+		name   string
+		viewer string
+		want   domain.Status
+	}
+	var check checks
+	checkViewers := func(cases []viewerCase) {
+		for _, c := range cases {
+			status, err := service.GetPublicUserStatus(owner, c.viewer)
+			if err != nil {
+				check.failf("%s: error getting public status: %v", c.name, err)
+			} else if check.expect(status.Status == c.want, "%s: expected %s, got %s", c.name, c.want, status.Status) {
+				check.expect(c.want == domain.StatusOnline || status.LastActivity == nil, "%s: masked status still shows last activity", c.name)
+			}
+		}
+	}
+
+	if _, err := service.SetVisibilityRules(owner, rules); err != nil {
+		log.Printf("❌ Error setting visibility rules: %v", err)
+		return
+	}
+	checkViewers([]viewerCase{
+		{"first matching rule wins", id(701), domain.StatusOnline},
+		{"offline rule", id(702), domain.StatusOffline},
+		{"hidden rule", id(703), domain.StatusUnknown},
+		{"viewer without rule gets the default", id(704), domain.StatusOnline},
+		{"anonymous viewer gets the default", "", domain.StatusOnline},
+	})
+
+	rules.Default = domain.VisibilityHidden
+	if _, err := service.SetVisibilityRules(owner, rules); err != nil {
+		log.Printf("❌ Error setting visibility rules: %v", err)
+		return
+	}
+	checkViewers([]viewerCase{
+		{"hidden by default", id(704), domain.StatusUnknown},
+		{"anonymous viewer hidden by default", "", domain.StatusUnknown},
+		{"listed viewer still visible", id(701), domain.StatusOnline},
+		{"viewer header claiming the owner gets the default", owner, domain.StatusUnknown},
+	})
+
+	if err := service.DeleteVisibilityRules(owner); err != nil {
+		check.failf("Error deleting visibility rules: %v", err)
+	}
+	checkViewers([]viewerCase{{"rules deleted", id(703), domain.StatusOnline}})
+
+	check.summary("Visibility rules applied per viewer (first match, default, anonymous, owner)")
+}
+
+func runLastSeenPrivacyTests(service *services.UserStatusService, contacts *services.ContactsService, id func(int) string) {
+	// Test 16: Last seen privacy is reciprocal: hiding yours hides others' from you
+	fmt.Println("\n16. Checking reciprocal last seen privacy between users 710 and 711...")
+	owner, viewer := id(710), id(711)
+	for _, userID := range []string{owner, viewer} {
+		if err := service.SetUserStatus(userID, domain.ClientSession{}, domain.StatusOnline); err != nil {
+			log.Printf("❌ Error setting user online: %v", err)
+			return
+		}
+	}
+	contacts.RemoveContact(owner, viewer)
+	contacts.RemoveContact(viewer, owner)
+
+	privacy := func(userID string, audience domain.Audience) {
+		if _, err := service.SetPrivacySettings(userID, domain.PrivacySettings{LastSeen: audience}); err != nil {
+			log.Printf("❌ Error setting privacy of %s: %v", userID, err)
+		}
+	}
+	contact := func(userID, contactID string) {
+		if err := contacts.AddContacts(userID, []string{contactID}); err != nil {
+			log.Printf("❌ Error adding contact: %v", err)
+		}
+	}
+
+	steps := []struct {
+		name   string
+		setup  func()
+		viewer string
+		want   bool // Owner's last seen visible to the viewer
+	}{
+		{"both share with everyone", func() { privacy(owner, domain.AudienceEveryone); privacy(viewer, domain.AudienceEveryone) }, viewer, true},
+		{"owner shares with contacts, viewer not a contact", func() { privacy(owner, domain.AudienceContacts) }, viewer, false},
+		{"anonymous viewer of contacts-only owner", func() {}, "", false},
+		{"viewer added to owner's contacts", func() { contact(owner, viewer) }, viewer, true},
+		{"viewer hides their own last seen", func() { privacy(viewer, domain.AudienceNobody) }, viewer, false},
+		{"viewer shares with contacts, owner not a contact", func() { privacy(viewer, domain.AudienceContacts) }, viewer, false},
+		{"owner added to viewer's contacts", func() { contact(viewer, owner) }, viewer, true},
+		{"owner shares with nobody", func() { privacy(owner, domain.AudienceNobody) }, viewer, false},
+		{"viewer header claiming the owner gets no bypass", func() {}, owner, false},
+		{"anonymous viewer of public owner", func() { privacy(owner, domain.AudienceEveryone) }, "", true},
+	}
+
+	var check checks
+	for _, step := range steps {
+		step.setup()
+
+		lastSeen, err := service.GetLastSeen(owner, step.viewer)
+		if err != nil {
+			check.failf("%s: error getting last seen: %v", step.name, err)
+			continue
+		}
+		bulk, err := service.GetMultiplePublicUserStatus([]string{owner}, step.viewer)
+		if err != nil {
+			check.failf("%s: error getting bulk public status: %v", step.name, err)
+			continue
+		}
+
+		single := lastSeen.LastSeen != nil
+		batched := bulk.Statuses[owner] != nil && bulk.Statuses[owner].LastActivity != nil
+		check.expect(single == step.want && batched == step.want, "%s: expected visible=%t, got %t (single) and %t (bulk)", step.name, step.want, single, batched)
+	}
+
+	privacy(viewer, domain.AudienceEveryone)
+	contacts.RemoveContact(owner, viewer)
+	contacts.RemoveContact(viewer, owner)
+
+	check.summary("All %d last seen privacy cases matched, single and bulk reads agree", len(steps))
+}
+
+func runBulkStatusRequestTests(svc router.Services, id func(int) string) {
+	// Test 17: Bulk user_ids parsing, per-ID errors and the batch limit through the API
+	fmt.Println("\n17. Requesting bulk public statuses through the router...")
+	gin.SetMode(gin.ReleaseMode)
+	gin.DefaultWriter = io.Discard // Keep the request log out of the test output
+	api := router.SetupRouter(svc, config.NewInternalAPIToken())
+
+	type bulkResponse struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors map[string]string          `json:"errors"`
+	}
+	request := func(method, target, body string) (int, bulkResponse) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+
+		var response bulkResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	a, b, c := id(720), id(721), id(722)
+	invalid := "not a valid id!"
+	cases := []struct {
+		name       string
+		query      string
+		wantCode   int
+		wantIDs    []string
+		wantErrors []string
+	}{
+		{"comma separated", "user_ids=" + a + "," + b, http.StatusOK, []string{a, b}, nil},
+		{"repeated parameters", "user_ids=" + a + "&user_ids=" + b, http.StatusOK, []string{a, b}, nil},
+		{"mixed, with blanks", "user_ids=" + a + ",%20,+" + b + "&user_ids=" + c, http.StatusOK, []string{a, b, c}, nil},
+		{"invalid ID reported per ID", "user_ids=" + a + "," + url.QueryEscape(invalid), http.StatusOK, []string{a}, []string{invalid}},
+		{"missing user_ids", "", http.StatusBadRequest, nil, nil},
+	}
+
+	var check checks
+	for _, tc := range cases {
+		code, response := request(http.MethodGet, "/api/v1/users/status/public?"+tc.query, "")
+		ok := code == tc.wantCode && len(response.Data) == len(tc.wantIDs) && len(response.Errors) == len(tc.wantErrors)
+		for _, userID := range tc.wantIDs {
+			_, found := response.Data[userID]
+			ok = ok && found
+		}
+		for _, userID := range tc.wantErrors {
+			_, found := response.Errors[userID]
+			ok = ok && found
+		}
+		check.expect(ok, "%s: got HTTP %d with %d statuses and errors %v", tc.name, code, len(response.Data), response.Errors)
+	}
+
+	// The batch limit applies to the POST form used for long lists
+	maxIDs := config.NewBulkMaxUserIDs()
+	for _, size := range []int{maxIDs, maxIDs + 1} {
+		userIDs := make([]string, size)
+		for i := range userIDs {
+			userIDs[i] = id(100000 + i)
+		}
+		body, _ := json.Marshal(map[string][]string{"user_ids": userIDs})
+
+		want := http.StatusOK
+		if size > maxIDs {
+			want = http.StatusBadRequest
+		}
+		code, _ := request(http.MethodPost, "/api/v1/users/status/public", string(body))
+		check.expect(code == want, "Batch of %d IDs (limit %d): expected HTTP %d, got %d", size, maxIDs, want, code)
+	}
+
+	check.summary("CSV and repeated user_ids parsed, invalid IDs reported per ID, batches capped at %d", maxIDs)
+}
+
+func runCustomStatusTests(service *services.UserStatusService, id func(int) string) {
+	// Test 18: Custom status is validated and outlives presence changes until cleared
+	fmt.Println("\n18. Setting a custom status for user 730 and changing presence underneath...")
+	userID := id(730)
+	var check checks
+
+	invalid := []struct {
+		name   string
+		custom domain.CustomStatus
+	}{
+		{"empty", domain.CustomStatus{Text: "   "}},
+		{"text too long", domain.CustomStatus{Text: strings.Repeat("a", 101)}},
+		{"line break", domain.CustomStatus{Text: "In a\nmeeting"}},
+		{"two emoji", domain.CustomStatus{Emoji: "📅📅"}},
+	}
+	for _, c := range invalid {
+		_, err := service.SetCustomStatus(userID, c.custom, 0)
+		check.expect(errors.Is(err, domain.ErrInvalidInput), "%s: expected invalid input, got %v", c.name, err)
+	}
+
+	if err := service.SetUserStatus(userID, domain.ClientSession{}, domain.StatusOnline); err != nil {
+		log.Printf("❌ Error setting user online: %v", err)
+		return
+	}
+	custom, err := service.SetCustomStatus(userID, domain.CustomStatus{Text: "  In a meeting until 3pm ", Emoji: "📅"}, time.Hour)
+	if err != nil {
+		log.Printf("❌ Error setting custom status: %v", err)
+		return
+	}
+	check.expect(custom.Text == "In a meeting until 3pm" && custom.ExpiresAt != nil, "Expected trimmed text with an expiry, got %+v", custom)
+
+	// Presence changes underneath must not touch the custom status
+	if err := service.SetUserAway(userID, domain.ClientSession{}); err != nil {
+		check.failf("Error setting user away: %v", err)
+	}
+	status, err := service.GetUserStatus(userID)
+	if err != nil {
+		check.failf("Error getting status: %v", err)
+	} else {
+		check.expect(status.Status == domain.StatusAway && status.CustomStatus != nil && status.CustomStatus.Text == custom.Text,
+			"Expected away with the custom status, got %s with %+v", status.Status, status.CustomStatus)
+	}
+
+	if err := service.ClearCustomStatus(userID); err != nil {
+		check.failf("Error clearing custom status: %v", err)
+	}
+	status, err = service.GetUserStatus(userID)
+	if err != nil {
+		check.failf("Error getting status: %v", err)
+	} else {
+		check.expect(status.CustomStatus == nil, "Custom status still set after clearing: %+v", status.CustomStatus)
+	}
+
+	check.summary("Custom status validated, kept across presence changes and cleared")
+}
+
+func runDNDTests(service *services.UserStatusService, id func(int) string) {
+	// Test 19: DND survives heartbeats, ends at its deadline and restores the automatic status
+	fmt.Println("\n19. Setting user 740 to do not disturb with a deadline...")
+	userID := id(740)
+	var check checks
+
+	if _, err := service.SendHeartbeat(userID, domain.ClientSession{}, nil); err != nil {
+		log.Printf("❌ Error sending heartbeat: %v", err)
+		return
+	}
+	_, err := service.SetUserDND(userID, ptr(time.Now().Add(-time.Minute)))
+	check.expect(errors.Is(err, domain.ErrInvalidInput), "DND ending in the past: expected invalid input, got %v", err)
+
+	until := time.Now().Add(time.Hour)
+	if _, err := service.SetUserDND(userID, &until); err != nil {
+		log.Printf("❌ Error setting DND: %v", err)
+		return
+	}
+	if _, err := service.SendHeartbeat(userID, domain.ClientSession{}, &domain.HeartbeatActivity{InputActive: ptr(true)}); err != nil {
+		check.failf("Error sending heartbeat: %v", err)
+	}
+	status, err := service.GetUserStatus(userID)
+	if err != nil {
+		check.failf("Error getting status: %v", err)
+	} else {
+		check.expect(status.Status == domain.StatusDND && status.DNDUntil != nil && status.DNDUntil.Equal(until),
+			"After a heartbeat: expected dnd until %s, got %s until %v", until.Format(time.RFC3339), status.Status, status.DNDUntil)
+	}
+
+	if err := service.ClearUserDND(userID); err != nil {
+		check.failf("Error clearing DND: %v", err)
+	}
+	status, err = service.GetUserStatus(userID)
+	if err != nil {
+		check.failf("Error getting status: %v", err)
+	} else {
+		check.expect(status.Status == domain.StatusOnline && status.DNDUntil == nil, "After clearing: expected online, got %s until %v", status.Status, status.DNDUntil)
+	}
+
+	// A short DND shows until its deadline, then the automatic status again
+	if _, err := service.SetUserDND(userID, ptr(time.Now().Add(time.Second))); err != nil {
+		check.failf("Error setting DND: %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := service.SendHeartbeat(userID, domain.ClientSession{}, nil); err != nil {
+		check.failf("Error sending heartbeat: %v", err)
+	}
+	status, err = service.GetUserStatus(userID)
+	if err != nil {
+		check.failf("Error getting status: %v", err)
+	} else {
+		check.expect(status.Status == domain.StatusOnline, "After the deadline: expected online, got %s", status.Status)
+	}
+
+	check.summary("DND kept across heartbeats until cleared or its deadline, then online again")
+}
+
+func runExpiryTests(client *redis.Client, repo domain.UserStatusRepository, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 20: Every instance's worker sees the same expiry, exactly one transitions it
+	fmt.Println("\n20. Handling the expiry of user 750's online status on 10 instances at once...")
+	userID := id(750)
+	var check checks
+	for round := 0; round < 10; round++ {
+		// Same state Redis leaves behind when the online status key expires
+		client.Del(ctx, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID), domain.GetUserSessionKey(userID, domain.DefaultSessionID))
+		if err := client.Set(ctx, "user:last_status:"+userID, string(domain.StatusOnline), time.Hour).Err(); err != nil {
+			log.Printf("❌ Error simulating the expiry: %v", err)
+			return
+		}
+
+		var workers sync.WaitGroup
+		events := make(chan *domain.StatusEvent, 10)
+		for i := 0; i < 10; i++ {
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				event, err := repo.ExpireUserStatus(userID)
+				if err != nil {
+					log.Printf("❌ Error expiring status: %v", err)
+				}
+				events <- event
+			}()
+		}
+		workers.Wait()
+		close(events)
+
+		var transitions []domain.StatusEvent
+		for event := range events {
+			if event != nil {
+				transitions = append(transitions, *event)
+			}
+		}
+		if !check.expect(len(transitions) == 1, "Round %d: expected one transition, got %d", round, len(transitions)) {
+			continue
+		}
+		event := transitions[0]
+		check.expect(event.OldStatus == domain.StatusOnline && event.NewStatus == domain.StatusAway && event.Cause == domain.CauseExpired,
+			"Round %d: expected online→away (expired), got %s→%s (%s)", round, event.OldStatus, event.NewStatus, event.Cause)
+	}
+
+	status, err := repo.GetUserStatus(userID)
+	if err != nil {
+		check.failf("Error getting status: %v", err)
+	} else {
+		check.expect(status == domain.StatusAway, "Expected away after the transition, got %s", status)
+	}
+
+	check.summary("Each of 10 expiries transitioned online→away exactly once")
+}
+
+func runStatusHistoryTests(client *redis.Client, service *services.UserStatusService, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 21: Explicit changes and auto-transitions are recorded, newest first, in pages
+	fmt.Println("\n21. Recording status history of user 760 (online, away, expiry to offline)...")
+	userID := id(760)
+	var check checks
+	// Start from a user without presence, also when the runner ran before
+	client.Del(ctx, domain.GetStatusHistoryKey(userID), "user:last_status:"+userID, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID), domain.GetUserSessionKey(userID, domain.DefaultSessionID))
+
+	if err := service.SetUserStatus(userID, domain.ClientSession{}, domain.StatusOnline); err != nil {
+		log.Printf("❌ Error setting user online: %v", err)
+		return
+	}
+	if err := service.SetUserAway(userID, domain.ClientSession{}); err != nil {
+		log.Printf("❌ Error setting user away: %v", err)
+		return
+	}
+	// Let the away status expire and read it back, which transitions it to offline
+	client.Del(ctx, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID), domain.GetUserSessionKey(userID, domain.DefaultSessionID))
+	if err := client.Set(ctx, "user:last_status:"+userID, string(domain.StatusAway), time.Hour).Err(); err != nil {
+		log.Printf("❌ Error simulating the expiry: %v", err)
+		return
+	}
+	if _, err := service.GetUserStatus(userID); err != nil {
+		log.Printf("❌ Error getting status: %v", err)
+		return
+	}
+
+	want := []struct {
+		old, new domain.Status
+		cause    string
+	}{
+		{domain.StatusAway, domain.StatusOffline, domain.CauseExpired},
+		{domain.StatusOnline, domain.StatusAway, domain.CauseExplicit},
+		{domain.StatusUnknown, domain.StatusOnline, domain.CauseExplicit},
+	}
+	var entries []domain.StatusHistoryEntry
+	query := domain.StatusHistoryQuery{Limit: 2}
+	for pages := 0; pages < len(want); pages++ {
+		page, next, err := service.GetStatusHistory(userID, query)
+		if err != nil {
+			check.failf("Error getting status history: %v", err)
+			break
+		}
+		entries = append(entries, page...)
+		if next == "" {
+			break
+		}
+		query.Cursor = next
+	}
+
+	if check.expect(len(entries) == len(want), "Expected %d entries over two pages, got %d", len(want), len(entries)) {
+		for i, entry := range entries {
+			check.expect(entry.OldStatus == want[i].old && entry.NewStatus == want[i].new && entry.Cause == want[i].cause,
+				"Entry %d: expected %s→%s (%s), got %s→%s (%s)", i, want[i].old, want[i].new, want[i].cause, entry.OldStatus, entry.NewStatus, entry.Cause)
+		}
+	}
+
+	_, _, err := service.GetStatusHistory(userID, domain.StatusHistoryQuery{Cursor: "not-a-cursor"})
+	check.expect(errors.Is(err, domain.ErrInvalidInput), "Invalid cursor: expected invalid input, got %v", err)
+
+	check.summary("Explicit changes and the expiry transition recorded newest first across pages")
+}
+
+func runManualStatusTests(client *redis.Client, service *services.UserStatusService, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 22: A manually chosen status sticks through heartbeats until changed or logged out
+	fmt.Println("\n22. Choosing away on user 770's laptop and sending heartbeats...")
+	userID := id(770)
+	laptop := domain.ClientSession{SessionID: "laptop", DeviceType: domain.DeviceDesktop}
+	phone := domain.ClientSession{SessionID: "phone", DeviceType: domain.DeviceMobile}
+	active := &domain.HeartbeatActivity{InputActive: ptr(true)}
+	var check checks
+
+	// Start from a user without sessions, also when the runner ran before
+	client.Del(ctx, domain.GetUserSessionsKey(userID), domain.GetUserSessionKey(userID, laptop.SessionID), domain.GetUserSessionKey(userID, phone.SessionID))
+
+	heartbeat := func(name string, session domain.ClientSession, want domain.Status) {
+		result, err := service.SendHeartbeat(userID, session, active)
+		if err != nil {
+			check.failf("%s: error sending heartbeat: %v", name, err)
+		} else {
+			check.expect(result.Status == want, "%s: expected %s, got %s", name, want, result.Status)
+		}
+	}
+
+	heartbeat("automatic session", laptop, domain.StatusOnline)
+	if err := service.SetUserAway(userID, laptop); err != nil {
+		log.Printf("❌ Error setting user away: %v", err)
+		return
+	}
+	heartbeat("active heartbeat after choosing away", laptop, domain.StatusAway)
+	heartbeat("active heartbeat from another device", phone, domain.StatusAway)
+
+	status, err := service.GetUserStatus(userID)
+	if err != nil {
+		check.failf("Error getting status: %v", err)
+	} else {
+		var source string
+		for _, session := range status.Sessions {
+			if session.SessionID == laptop.SessionID && session.Status == domain.StatusAway {
+				source = session.Source
+			}
+		}
+		check.expect(source == domain.SourceManual, "Laptop session: expected a manual away session, got %+v", status.Sessions)
+	}
+
+	if err := service.EndSession(userID, laptop.SessionID); err != nil {
+		check.failf("Error ending session: %v", err)
+	}
+	heartbeat("heartbeat after logging the laptop out", phone, domain.StatusOnline)
+
+	check.summary("Manual away kept through heartbeats on every device until logout")
+}
+
+func runPresencePolicyTests(client *redis.Client, service *services.UserStatusService, policy domain.PresencePolicy, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 23: The presence policy comes from configuration and drives service and repository
+	fmt.Println("\n23. Loading presence policies from environment and applying a custom transition table...")
+	var check checks
+
+	// withEnv loads the policy with the given variables set, restoring them afterwards
+	withEnv := func(env map[string]string) (domain.PresencePolicy, error) {
+		for key, value := range env {
+			previous, had := os.LookupEnv(key)
+			os.Setenv(key, value)
+			if had {
+				defer os.Setenv(key, previous)
+			} else {
+				defer os.Unsetenv(key)
+			}
+		}
+		return config.NewPresencePolicy()
+	}
+
+	custom, err := withEnv(map[string]string{
+		"PRESENCE_ONLINE_TTL":                "45s",
+		"PRESENCE_HEARTBEAT_INTERVAL_MOBILE": "90s",
+		"PRESENCE_TRANSITIONS":               "online:offline,away:offline,invisible:offline,dnd:offline",
+		"PRESENCE_AWAY_HEARTBEAT_MULTIPLIER": "2",
+	})
+	if err != nil {
+		check.failf("Error loading custom policy: %v", err)
+	} else {
+		check.expect(custom.StatusTTL(domain.StatusOnline) == 45*time.Second && custom.HeartbeatIntervalFor(domain.DeviceMobile) == 90*time.Second &&
+			custom.Transitions[domain.StatusOnline] == domain.StatusOffline && custom.AwayMultiplier == 2,
+			"Custom policy not applied: %+v", custom)
+	}
+
+	invalid := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"TTL within the expiry lock", map[string]string{"PRESENCE_ONLINE_TTL": "5s"}},
+		{"unknown transition target", map[string]string{"PRESENCE_TRANSITIONS": "online:busy"}},
+		{"malformed duration", map[string]string{"PRESENCE_HEARTBEAT_GRACE": "soon"}},
+		{"max interval below the interval", map[string]string{"PRESENCE_MAX_HEARTBEAT_INTERVAL": "10s"}},
+	}
+	for _, c := range invalid {
+		_, err := withEnv(c.env)
+		check.expect(err != nil, "%s: policy accepted", c.name)
+	}
+
+	// The policy the service runs with is what clients are told
+	view, err := service.GetPresencePolicy(domain.DeviceWeb)
+	if err != nil {
+		check.failf("Error getting presence policy: %v", err)
+	} else {
+		for status, ttl := range policy.StatusTTLs {
+			want := int64(policy.SessionTTL(status, domain.DeviceWeb, view.LoadMultiplier).Seconds())
+			check.expect(view.StatusTTLSeconds[status] == want, "Reported %s TTL: expected %ds, got %ds (configured %s)", status, want, view.StatusTTLSeconds[status], ttl)
+		}
+		for from, to := range policy.Transitions {
+			check.expect(view.Transitions[from] == to, "Reported transition of %s: expected %s, got %s", from, to, view.Transitions[from])
+		}
+	}
+
+	// The repository transitions expired statuses with the injected table
+	if custom.Transitions != nil {
+		userID := id(780)
+		repo := repository.NewRedisUserStatusRepository(client, custom, config.NewStatusHistoryRetention())
+		client.Del(ctx, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID))
+		if err := client.Set(ctx, "user:last_status:"+userID, string(domain.StatusOnline), time.Hour).Err(); err != nil {
+			check.failf("Error simulating the expiry: %v", err)
+		} else if status, err := repo.GetUserStatus(userID); err != nil {
+			check.failf("Error getting status: %v", err)
+		} else {
+			check.expect(status == domain.StatusOffline, "Expired online with custom transitions: expected offline, got %s", status)
+		}
+	}
+
+	check.summary("Policy loaded from environment, invalid policies rejected, reported and applied as configured")
+}
+
+func runHeartbeatIntervalTests(client *redis.Client, service *services.UserStatusService, policy domain.PresencePolicy, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 24: Heartbeats negotiate the next interval and keep the session alive until then
+	fmt.Println("\n24. Negotiating heartbeat intervals per device type and status...")
+	var check checks
+
+	view, err := service.GetPresencePolicy(domain.DeviceWeb)
+	if err != nil {
+		check.failf("Error getting presence policy: %v", err)
+		return
+	}
+	load := view.LoadMultiplier
+
+	cases := []struct {
+		device   string
+		activity *domain.HeartbeatActivity
+		status   domain.Status
+	}{
+		{domain.DeviceWeb, nil, domain.StatusOnline},
+		{domain.DeviceMobile, nil, domain.StatusOnline},
+		{domain.DeviceWeb, &domain.HeartbeatActivity{IdleSeconds: 600}, domain.StatusAway},
+		{domain.DeviceMobile, &domain.HeartbeatActivity{IdleSeconds: 600}, domain.StatusAway},
+	}
+	intervals := make(map[string]time.Duration)
+	for i, c := range cases {
+		userID := id(790 + i)
+		session := domain.ClientSession{SessionID: "interval", DeviceType: c.device}
+		client.Del(ctx, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID), domain.GetUserSessionKey(userID, session.SessionID))
+
+		result, err := service.SendHeartbeat(userID, session, c.activity)
+		if err != nil {
+			check.failf("%s %s: error sending heartbeat: %v", c.device, c.status, err)
+			continue
+		}
+		want := policy.NextHeartbeatInterval(c.device, c.status, load)
+		check.expect(result.HeartbeatInterval == want, "%s %s: expected interval %s, got %s", c.device, c.status, want, result.HeartbeatInterval)
+		check.expect(result.HeartbeatInterval <= policy.MaxHeartbeat, "%s %s: interval %s above the maximum %s", c.device, c.status, result.HeartbeatInterval, policy.MaxHeartbeat)
+		intervals[c.device+"/"+string(c.status)] = result.HeartbeatInterval
+
+		// The session must outlive the negotiated interval plus grace
+		ttl, err := client.PTTL(ctx, domain.GetUserSessionKey(userID, session.SessionID)).Result()
+		if err != nil {
+			check.failf("%s %s: error reading session TTL: %v", c.device, c.status, err)
+			continue
+		}
+		check.expect(ttl >= result.HeartbeatInterval+policy.HeartbeatGrace-time.Second,
+			"%s %s: session TTL %s shorter than interval %s plus grace %s", c.device, c.status, ttl, result.HeartbeatInterval, policy.HeartbeatGrace)
+	}
+
+	check.expect(intervals["mobile/online"] > intervals["web/online"], "Mobile interval %s not longer than web %s", intervals["mobile/online"], intervals["web/online"])
+	check.expect(intervals["web/away"] > intervals["web/online"], "Away interval %s not longer than online %s", intervals["web/away"], intervals["web/online"])
+
+	// Server load stretches intervals up to the maximum
+	check.expect(policy.NextHeartbeatInterval(domain.DeviceWeb, domain.StatusOnline, 2) == min(2*policy.HeartbeatIntervalFor(domain.DeviceWeb), policy.MaxHeartbeat),
+		"Load multiplier 2 not applied to the web interval")
+	check.expect(policy.NextHeartbeatInterval(domain.DeviceMobile, domain.StatusAway, 100) == policy.MaxHeartbeat, "Interval under heavy load not capped at the maximum")
+
+	check.summary("Intervals adapt to device, status and load, and sessions outlive them")
+}
+
+func runStatusTypeTests(client *redis.Client, service *services.UserStatusService, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 25: Every status parses, serializes and round-trips through all layers the same way
+	fmt.Println("\n25. Round-tripping every status through parsing, JSON and storage...")
+	var check checks
+
+	for i, status := range domain.Statuses {
+		if i > 0 {
+			check.expect(status.Precedence() < domain.Statuses[i-1].Precedence(), "Statuses not ordered by precedence at %s", status)
+		}
+
+		parsed, err := domain.ParseStatus(" " + strings.ToUpper(string(status)) + " ")
+		check.expect(err == nil && parsed == status, "Parsing %s: got %q, %v", status, parsed, err)
+
+		data, err := json.Marshal(domain.UserStatus{Status: status})
+		var decoded domain.UserStatus
+		if err == nil {
+			err = json.Unmarshal(data, &decoded)
+		}
+		check.expect(err == nil && decoded.Status == status, "JSON round trip of %s: got %q, %v", status, decoded.Status, err)
+
+		if to, ok := domain.DefaultTransitions[status]; ok {
+			check.expect(to.IsValid(), "Transition of %s targets invalid status %q", status, to)
+		}
+
+		// Set from a live session and read back through the service and repository
+		userID := id(800 + i)
+		session := domain.ClientSession{SessionID: "typed"}
+		client.Del(ctx, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID), domain.GetUserSessionKey(userID, session.SessionID), domain.GetUserDNDKey(userID))
+		if _, err := service.SendHeartbeat(userID, session, nil); err != nil {
+			check.failf("%s: error sending heartbeat: %v", status, err)
+			continue
+		}
+		if err := service.SetUserStatus(userID, session, status); err != nil {
+			check.failf("%s: error setting status: %v", status, err)
+			continue
+		}
+		if got, err := service.GetUserStatus(userID); err != nil {
+			check.failf("%s: error getting status: %v", status, err)
+		} else {
+			check.expect(got.Status == status, "Stored %s read back as %s", status, got.Status)
+		}
+	}
+
+	for _, value := range []string{"", "busy", "unknown", "on line"} {
+		_, err := domain.ParseStatus(value)
+		check.expect(err != nil, "ParseStatus accepted %q", value)
+	}
+	var decoded domain.UserStatus
+	check.expect(json.Unmarshal([]byte(`{"status":"busy"}`), &decoded) != nil, "JSON accepted status busy")
+	check.expect(json.Unmarshal([]byte(`{"status":"unknown"}`), &decoded) == nil && decoded.Status == domain.StatusUnknown, "JSON rejected the unknown status reads report")
+
+	err := service.SetUserStatus(id(806), domain.ClientSession{}, domain.Status("busy"))
+	check.expect(errors.Is(err, domain.ErrInvalidInput), "Setting status busy: expected invalid input, got %v", err)
+
+	check.summary("All %d statuses parse, serialize and round-trip through storage, invalid values rejected", len(domain.Statuses))
+}
+
+func runUserIDSchemeTests(client *redis.Client, repo domain.UserStatusRepository, contacts domain.ContactsRepository, policy domain.PresencePolicy) {
+	ctx := context.Background()
+
+	// Test 26: Each user ID scheme validates and normalizes IDs so a user has one set of keys
+	fmt.Println("\n26. Validating and normalizing user IDs per scheme...")
+	var check checks
+
+	schemes := []struct {
+		scheme   domain.UserIDScheme
+		valid    map[string]string // raw → canonical
+		rejected []string
+	}{
+		{
+			domain.UserIDScheme{Kind: domain.UserIDSchemePrefix, Prefix: "user_", MaxLength: 12, FoldCase: true},
+			map[string]string{" User_42 ": "user_42", "user_abc": "user_abc"},
+			[]string{"", "   ", "42", "user_", "user_a:b", "user_a b", "user_toolong99"},
+		},
+		{
+			domain.UserIDScheme{Kind: domain.UserIDSchemeUUID},
+			map[string]string{"\t3F2504E0-4F89-41D3-9A0C-0305E82C3301\n": "3f2504e0-4f89-41d3-9a0c-0305e82c3301"},
+			[]string{"3f2504e0-4f89-41d3-9a0c", "user_42", "3f2504e0-4f89-41d3-9a0c-0305e82c330g"},
+		},
+		{
+			domain.UserIDScheme{Kind: domain.UserIDSchemeSnowflake},
+			map[string]string{"0042": "42", " 1541815603606036480 ": "1541815603606036480"},
+			[]string{"0", "-42", "4x2", "18446744073709551616"},
+		},
+		{
+			domain.UserIDScheme{Kind: domain.UserIDSchemeRegex, Pattern: `[a-z]{2}-[0-9]+`, FoldCase: true},
+			map[string]string{"EU-7": "eu-7"},
+			[]string{"eu-", "eu-7x", "x eu-7"},
+		},
+	}
+	for _, s := range schemes {
+		ids, err := services.NewUserIDValidator(s.scheme)
+		if err != nil {
+			check.failf("%s: error creating validator: %v", s.scheme.Kind, err)
+			continue
+		}
+		for raw, want := range s.valid {
+			got, err := ids.Normalize(raw)
+			check.expect(err == nil && got == want, "%s: normalizing %q: expected %q, got %q, %v", s.scheme.Kind, raw, want, got, err)
+		}
+		for _, raw := range s.rejected {
+			_, err := ids.Normalize(raw)
+			check.expect(err != nil, "%s: accepted %q", s.scheme.Kind, raw)
+		}
+	}
+
+	for _, scheme := range []domain.UserIDScheme{{Kind: "email"}, {Kind: domain.UserIDSchemePrefix}, {Kind: domain.UserIDSchemeRegex, Pattern: "("}} {
+		_, err := services.NewUserIDValidator(scheme)
+		check.expect(err != nil, "Accepted scheme %+v", scheme)
+	}
+
+	// A service using UUIDs writes and reads one key whatever the spelling
+	ids, _ := services.NewUserIDValidator(domain.UserIDScheme{Kind: domain.UserIDSchemeUUID})
+	service := services.NewUserStatusService(repo, contacts, policy, ids, 100)
+	canonical := "3f2504e0-4f89-41d3-9a0c-0305e82c3301"
+	spelled := " 3F2504E0-4F89-41D3-9A0C-0305E82C3301 "
+	session := domain.ClientSession{SessionID: "ids"}
+	client.Del(ctx, domain.GetUserStatusKey(canonical), domain.GetUserSessionsKey(canonical), domain.GetUserSessionKey(canonical, session.SessionID))
+
+	if err := service.SetUserStatus(spelled, session, domain.StatusAway); err != nil {
+		check.failf("Error setting status for %q: %v", spelled, err)
+	} else if status, err := service.GetUserStatus(canonical); err != nil {
+		check.failf("Error getting status: %v", err)
+	} else {
+		check.expect(status.UserID == canonical && status.Status == domain.StatusAway, "Status set as %q read as %s for %q", spelled, status.Status, status.UserID)
+		keys, _ := client.Keys(ctx, "*3F2504E0*").Result()
+		check.expect(len(keys) == 0, "Keys created for the unnormalized ID: %v", keys)
+	}
+	_, err := service.GetUserStatus("user_42")
+	check.expect(errors.Is(err, domain.ErrInvalidInput), "ID from another scheme: expected invalid input, got %v", err)
+
+	check.summary("IDs normalized per scheme, invalid IDs and schemes rejected, one key per user")
+}
+
+func runBulkTransitionTests(client *redis.Client, service *services.UserStatusService, policy domain.PresencePolicy, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 27: Bulk reads apply expiry transitions like single reads, in few round trips
+	fmt.Println("\n27. Comparing bulk and single reads of expired statuses...")
+	var check checks
+
+	expired := map[string]domain.Status{
+		id(820): domain.StatusOnline,    // → away
+		id(821): domain.StatusAway,      // → offline
+		id(822): domain.StatusInvisible, // → away
+	}
+	live, unseen := id(823), id(824)
+	userIDs := []string{live, unseen}
+	for userID, last := range expired {
+		userIDs = append(userIDs, userID)
+		client.Del(ctx, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID))
+		if err := client.Set(ctx, "user:last_status:"+userID, string(last), time.Hour).Err(); err != nil {
+			check.failf("Error simulating the expiry of %s: %v", userID, err)
+		}
+	}
+	client.Del(ctx, domain.GetUserStatusKey(unseen), domain.GetUserSessionsKey(unseen), "user:last_status:"+unseen)
+	if _, err := service.SendHeartbeat(live, domain.ClientSession{SessionID: "bulk"}, nil); err != nil {
+		check.failf("Error sending heartbeat: %v", err)
+	}
+
+	// The bulk read goes first so it is the one that performs the transitions
+	bulk, err := service.GetMultipleUserStatus(userIDs)
+	if err != nil {
+		check.failf("Error getting bulk status: %v", err)
+		return
+	}
+	for _, userID := range userIDs {
+		single, err := service.GetUserStatus(userID)
+		if err != nil {
+			check.failf("Error getting status of %s: %v", userID, err)
+			continue
+		}
+		got := bulk.Statuses[userID]
+		check.expect(got != nil && got.Status == single.Status, "%s: bulk read %v, single read %s", userID, got, single.Status)
+		if last, ok := expired[userID]; ok {
+			check.expect(single.Status == policy.Transitions[last], "%s expired from %s: expected %s, got %s", userID, last, policy.Transitions[last], single.Status)
+		}
+	}
+	check.expect(bulk.Statuses[live] != nil && bulk.Statuses[live].Status == domain.StatusOnline, "Live user not online in bulk read")
+	check.expect(bulk.Statuses[unseen] != nil && bulk.Statuses[unseen].Status == domain.StatusUnknown, "Unseen user not unknown in bulk read")
+
+	// A large lookup against the repository stays within a couple of round trips
+	counter := &commandCounter{}
+	counted := redis.NewClient(client.Options())
+	defer counted.Close()
+	counted.AddHook(counter)
+	repo := repository.NewRedisUserStatusRepository(counted, policy, config.NewStatusHistoryRetention())
+
+	many := make([]string, 500)
+	for i := range many {
+		many[i] = id(10000 + i)
+	}
+	many = append(many, userIDs...)
+	statuses, err := repo.GetMultipleUserStatus(many)
+	if err != nil {
+		check.failf("Error getting %d statuses: %v", len(many), err)
+	} else {
+		check.expect(len(statuses) == len(many), "Expected %d statuses, got %d", len(many), len(statuses))
+		check.expect(counter.roundTrips <= 2, "Bulk read of %d users took %d round trips", len(many), counter.roundTrips)
+	}
+
+	check.summary("Bulk reads agree with single reads after expiry, %d users read with %d round trip(s)", len(many), counter.roundTrips)
+}
+
+// commandCounter counts round trips to Redis: single commands and whole pipelines
+type commandCounter struct {
+	roundTrips int
+}
+
+func (c *commandCounter) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (c *commandCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		c.roundTrips++
+		return next(ctx, cmd)
+	}
+}
+
+func (c *commandCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		c.roundTrips++
+		return next(ctx, cmds)
+	}
+}
+
+func runBulkPublicStatusTests(svc router.Services, id func(int) string) {
+	// Test 28: Bulk public reads mask invisibility; raw bulk reads are internal only
+	fmt.Println("\n28. Reading an invisible user through the public and internal bulk endpoints...")
+	gin.SetMode(gin.ReleaseMode)
+	gin.DefaultWriter = io.Discard
+	const token = "runner-internal-token"
+	api := router.SetupRouter(svc, token)
+	var check checks
+
+	hidden, visible, viewer := id(830), id(831), id(832)
+	if err := svc.UserStatus.SetUserInvisible(hidden, domain.ClientSession{SessionID: "public"}); err != nil {
+		check.failf("Error setting user invisible: %v", err)
+	}
+	if _, err := svc.UserStatus.SendHeartbeat(visible, domain.ClientSession{SessionID: "public"}, nil); err != nil {
+		check.failf("Error sending heartbeat: %v", err)
+	}
+
+	request := func(method, target, token, body string) (int, map[string]json.RawMessage) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if token != "" {
+			req.Header.Set(router.InternalTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+
+		var response struct {
+			Data map[string]json.RawMessage `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response.Data
+	}
+	statusOf := func(data map[string]json.RawMessage, userID string) (domain.UserStatus, bool) {
+		var status domain.UserStatus
+		raw, ok := data[userID]
+		return status, ok && json.Unmarshal(raw, &status) == nil
+	}
+
+	query := "?user_ids=" + hidden + "," + visible
+	body := `{"user_ids":["` + hidden + `","` + visible + `"]}`
+
+	// Public: GET and POST mask invisible as offline and never leak the actual status
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		target, payload := "/api/v1/users/status/public"+query, ""
+		if method == http.MethodPost {
+			target, payload = "/api/v1/users/status/public", body
+		}
+		code, data := request(method, target, "", payload)
+		check.expect(code == http.StatusOK, "Public %s: expected HTTP 200, got %d", method, code)
+		status, ok := statusOf(data, hidden)
+		check.expect(ok && status.Status == domain.StatusOffline, "Public %s: invisible user shown as %s", method, status.Status)
+		check.expect(!strings.Contains(string(data[hidden]), "actual_status"), "Public %s: actual status leaked: %s", method, data[hidden])
+		status, ok = statusOf(data, visible)
+		check.expect(ok && status.Status == domain.StatusOnline, "Public %s: online user shown as %s", method, status.Status)
+	}
+
+	// The service method masks each user exactly like the single public read
+	bulk, err := svc.UserStatus.GetMultiplePublicUserStatus([]string{hidden, visible}, viewer)
+	if err != nil {
+		check.failf("Error getting bulk public status: %v", err)
+	} else {
+		for _, userID := range []string{hidden, visible} {
+			single, err := svc.UserStatus.GetPublicUserStatus(userID, viewer)
+			got := bulk.Statuses[userID]
+			check.expect(err == nil && got != nil && got.Status == single.Status && got.ActualStatus == "",
+				"%s: bulk public %v, single public %v (%v)", userID, got, single, err)
+		}
+	}
+
+	// Raw: only internal callers, who see the real status
+	for _, caller := range []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"without token", "", http.StatusForbidden},
+		{"with wrong token", "guess", http.StatusForbidden},
+		{"with token", token, http.StatusOK},
+	} {
+		code, data := request(http.MethodGet, "/api/v1/users/status"+query, caller.token, "")
+		check.expect(code == caller.want, "Raw GET %s: expected HTTP %d, got %d", caller.name, caller.want, code)
+		postCode, _ := request(http.MethodPost, "/api/v1/users/status", caller.token, body)
+		check.expect(postCode == caller.want, "Raw POST %s: expected HTTP %d, got %d", caller.name, caller.want, postCode)
+		if caller.want == http.StatusOK {
+			status, ok := statusOf(data, hidden)
+			check.expect(ok && status.Status == domain.StatusInvisible, "Raw GET: invisible user shown as %s", status.Status)
+		}
+	}
+
+	// An empty configured token closes the raw endpoints entirely
+	closed := router.SetupRouter(svc, "")
+	w := httptest.NewRecorder()
+	closed.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users/status"+query, nil))
+	check.expect(w.Code == http.StatusForbidden, "Raw GET without a configured token: expected HTTP 403, got %d", w.Code)
+
+	check.summary("Bulk public reads mask invisible users, raw bulk reads restricted to internal callers")
+}
+
+func runRoomPresenceTests(client *redis.Client, svc router.Services, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 29: Room members ride on the user status lifecycle and drop out when it ends
+	fmt.Println("\n29. Joining a room and letting members go away, offline and invisible...")
+	var check checks
+
+	roomID := "runner-room"
+	online, away, hidden, unseen, leaving := id(840), id(841), id(842), id(843), id(844)
+	session := domain.ClientSession{SessionID: "room"}
+	client.Del(ctx, domain.GetRoomMembersKey(roomID))
+	for _, userID := range []string{online, away, hidden, unseen, leaving} {
+		client.Del(ctx, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID), domain.GetUserSessionKey(userID, session.SessionID),
+			domain.GetUserDNDKey(userID), "user:last_status:"+userID)
+	}
+
+	for _, userID := range []string{online, away, leaving} {
+		if _, err := svc.UserStatus.SendHeartbeat(userID, session, nil); err != nil {
+			check.failf("Error sending heartbeat for %s: %v", userID, err)
+		}
+	}
+	if err := svc.UserStatus.SetUserStatus(away, session, domain.StatusAway); err != nil {
+		check.failf("Error setting away: %v", err)
+	}
+	if err := svc.UserStatus.SetUserInvisible(hidden, session); err != nil {
+		check.failf("Error setting invisible: %v", err)
+	}
+
+	for _, userID := range []string{online, away, hidden, leaving} {
+		if err := svc.Rooms.JoinRoom(roomID, userID); err != nil {
+			check.failf("Error joining %s: %v", userID, err)
+		}
+	}
+	err := svc.Rooms.JoinRoom(roomID, unseen)
+	check.expect(errors.Is(err, domain.ErrUserNotPresent), "User without presence joining: expected not present, got %v", err)
+	err = svc.Rooms.JoinRoom("not a room!", online)
+	check.expect(errors.Is(err, domain.ErrInvalidInput), "Invalid room ID: expected invalid input, got %v", err)
+
+	// memberStatuses lists the visible members in order, checking the count endpoint agrees
+	memberStatuses := func() []string {
+		presence, err := svc.Rooms.GetRoomPresence(roomID, "")
+		if err != nil {
+			check.failf("Error getting room presence: %v", err)
+			return nil
+		}
+		count, err := svc.Rooms.GetRoomCount(roomID, "")
+		if err != nil {
+			check.failf("Error getting room count: %v", err)
+			return nil
+		}
+		check.expect(count.Online == presence.Online && fmt.Sprint(count.Counts) == fmt.Sprint(presence.Counts),
+			"Room count %d %v disagrees with presence %d %v", count.Online, count.Counts, presence.Online, presence.Counts)
+
+		members := make([]string, len(presence.Members))
+		for i, member := range presence.Members {
+			members[i] = member.UserID + "=" + string(member.Status)
+		}
+		return members
+	}
+	isMember := func(userID string) bool {
+		member, _ := client.SIsMember(ctx, domain.GetRoomMembersKey(roomID), userID).Result()
+		return member
+	}
+
+	want := []string{online + "=online", leaving + "=online", away + "=away"}
+	got := memberStatuses()
+	check.expect(fmt.Sprint(got) == fmt.Sprint(want), "Members: expected %v, got %v", want, got)
+	check.expect(isMember(hidden), "Invisible member removed from the room instead of hidden")
+
+	// Going offline, leaving and expiring all take members out of the room
+	if err := svc.UserStatus.SetUserOffline(leaving, session); err != nil {
+		check.failf("Error setting offline: %v", err)
+	}
+	if err := svc.Rooms.LeaveRoom(roomID, online); err != nil {
+		check.failf("Error leaving room: %v", err)
+	}
+	client.Del(ctx, domain.GetUserStatusKey(away), domain.GetUserSessionsKey(away), domain.GetUserSessionKey(away, session.SessionID))
+	if err := client.Set(ctx, "user:last_status:"+away, string(domain.StatusAway), time.Hour).Err(); err != nil {
+		check.failf("Error simulating the expiry: %v", err)
+	}
+
+	got = memberStatuses()
+	check.expect(len(got) == 0, "Members after offline, leave and expiry: expected none, got %v", got)
+	for _, userID := range []string{online, away, leaving} {
+		check.expect(!isMember(userID), "%s still stored as a room member", userID)
+	}
+	check.expect(isMember(hidden), "Invisible member pruned from the room")
+
+	check.summary("Members listed by status, invisible hidden, offline, leaving and expired members dropped")
+}
+
+func runTypingTests(client *redis.Client, svc router.Services, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 30: Typing indicators expire on their own, fan out, and never reveal invisible users
+	fmt.Println("\n30. Typing in a conversation as online, invisible and unseen users...")
+	var check checks
+
+	conversationID := "runner-conversation"
+	alice, bob, hidden, unseen, stale := id(850), id(851), id(852), id(853), id(854)
+	session := domain.ClientSession{SessionID: "typing"}
+	client.Del(ctx, domain.GetTypingKey(conversationID))
+	for _, userID := range []string{alice, bob, hidden, unseen, stale} {
+		client.Del(ctx, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID), domain.GetUserSessionKey(userID, session.SessionID), "user:last_status:"+userID)
+	}
+	for _, userID := range []string{alice, bob, stale} {
+		if _, err := svc.UserStatus.SendHeartbeat(userID, session, nil); err != nil {
+			check.failf("Error sending heartbeat for %s: %v", userID, err)
+		}
+	}
+	if err := svc.UserStatus.SetUserInvisible(hidden, session); err != nil {
+		check.failf("Error setting invisible: %v", err)
+	}
+
+	events := client.Subscribe(ctx, domain.GetTypingEventsChannel(conversationID))
+	defer events.Close()
+	if _, err := events.Receive(ctx); err != nil {
+		check.failf("Error subscribing to typing events: %v", err)
+		return
+	}
+
+	for _, userID := range []string{alice, bob} {
+		shown, err := svc.Typing.StartTyping(conversationID, userID)
+		check.expect(err == nil && shown, "%s starting to type: got %t, %v", userID, shown, err)
+	}
+	shown, err := svc.Typing.StartTyping(conversationID, hidden)
+	check.expect(err == nil && !shown, "Invisible user starting to type: expected not shown, got %t, %v", shown, err)
+	_, err = svc.Typing.StartTyping(conversationID, unseen)
+	check.expect(errors.Is(err, domain.ErrUserNotPresent), "User without presence typing: expected not present, got %v", err)
+	_, err = svc.Typing.StartTyping("not a conversation!", alice)
+	check.expect(errors.Is(err, domain.ErrInvalidInput), "Invalid conversation ID: expected invalid input, got %v", err)
+
+	// An indicator past its expiry is dropped on read even while the key lives on
+	client.ZAdd(ctx, domain.GetTypingKey(conversationID), redis.Z{Score: float64(time.Now().Add(-time.Second).UnixMilli()), Member: stale})
+	ttl, _ := client.PTTL(ctx, domain.GetTypingKey(conversationID)).Result()
+	check.expect(ttl > 0 && ttl <= domain.TypingTTL, "Typing key TTL: expected at most %s, got %s", domain.TypingTTL, ttl)
+
+	typing := func(viewerID string) []string {
+		state, err := svc.Typing.GetTypingUsers(conversationID, viewerID)
+		if err != nil {
+			check.failf("Error getting typing users: %v", err)
+			return nil
+		}
+		return state.UserIDs
+	}
+	check.expect(fmt.Sprint(typing("")) == fmt.Sprint([]string{alice, bob}), "Typing users: expected [%s %s], got %v", alice, bob, typing(""))
+	check.expect(fmt.Sprint(typing(alice)) == fmt.Sprint([]string{bob}), "Typing users seen by %s: expected [%s], got %v", alice, bob, typing(alice))
+
+	if err := svc.Typing.StopTyping(conversationID, alice); err != nil {
+		check.failf("Error stopping typing: %v", err)
+	}
+	if err := svc.Typing.StopTyping(conversationID, alice); err != nil {
+		check.failf("Error stopping typing twice: %v", err)
+	}
+	check.expect(fmt.Sprint(typing("")) == fmt.Sprint([]string{bob}), "Typing users after stop: expected [%s], got %v", bob, typing(""))
+
+	// Subscribers got the two starts and a single stop, nothing for the invisible user
+	var received []string
+	timeout := time.After(time.Second)
+collect:
+	for len(received) < 4 {
+		select {
+		case msg := <-events.Channel():
+			var event domain.TypingEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				check.failf("Malformed typing event %s: %v", msg.Payload, err)
+				continue
+			}
+			received = append(received, fmt.Sprintf("%s=%t", event.UserID, event.Typing))
+			check.expect(!event.Typing || event.ExpiresInMs == domain.TypingTTL.Milliseconds(), "Start event expires in %dms", event.ExpiresInMs)
+		case <-timeout:
+			break collect
+		}
+	}
+	want := []string{alice + "=true", bob + "=true", alice + "=false"}
+	check.expect(fmt.Sprint(received) == fmt.Sprint(want), "Typing events: expected %v, got %v", want, received)
+
+	check.summary("Typing shown and fanned out, expired indicators dropped, invisible users never revealed")
+}
+
+func runPresenceStatsTests(client *redis.Client, repo domain.UserStatusRepository, svc router.Services, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 31: Presence counters follow every transition, including expiries nobody reported
+	fmt.Println("\n31. Counting users per status across changes and expiries...")
+	var check checks
+
+	online, hidden, busy, silent := id(860), id(861), id(862), id(863)
+	session := domain.ClientSession{SessionID: "stats"}
+	for _, userID := range []string{online, hidden, busy, silent} {
+		client.Del(ctx, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID), domain.GetUserSessionKey(userID, session.SessionID),
+			domain.GetUserDNDKey(userID), "user:last_status:"+userID)
+		for shard := 0; shard < domain.PresenceStatsShards; shard++ {
+			for _, status := range domain.Statuses {
+				client.ZRem(ctx, domain.GetPresenceStatsKey(shard, status), userID)
+			}
+		}
+	}
+
+	stats := func() *domain.PresenceStats {
+		stats, err := svc.PresenceStats.GetPresenceStats(time.Time{})
+		if err != nil {
+			check.failf("Error getting presence stats: %v", err)
+			return &domain.PresenceStats{Counts: map[domain.Status]int64{}}
+		}
+		return stats
+	}
+	// expectDelta compares the counts against the baseline
+	expectDelta := func(step string, before, after *domain.PresenceStats, online int64, deltas map[domain.Status]int64) {
+		check.expect(after.Online-before.Online == online, "%s: online changed by %d, expected %d", step, after.Online-before.Online, online)
+		for _, status := range domain.Statuses {
+			got := after.Counts[status] - before.Counts[status]
+			check.expect(got == deltas[status], "%s: %s count changed by %d, expected %d", step, status, got, deltas[status])
+		}
+	}
+
+	before := stats()
+	for _, userID := range []string{online, hidden, busy, silent} {
+		if _, err := svc.UserStatus.SendHeartbeat(userID, session, nil); err != nil {
+			check.failf("Error sending heartbeat for %s: %v", userID, err)
+		}
+	}
+	if err := svc.UserStatus.SetUserInvisible(hidden, session); err != nil {
+		check.failf("Error setting invisible: %v", err)
+	}
+	if err := svc.UserStatus.SetUserStatus(busy, session, domain.StatusDND); err != nil {
+		check.failf("Error setting DND: %v", err)
+	}
+	after := stats()
+	expectDelta("After going online", before, after, 3, map[domain.Status]int64{domain.StatusOnline: 2, domain.StatusInvisible: 1, domain.StatusDND: 1})
+	check.expect(after.DailyActive > 0 && after.Date == time.Now().UTC().Format(time.DateOnly), "Daily active for %s: %d", after.Date, after.DailyActive)
+
+	// The expiry worker moves the count from online to away
+	client.Del(ctx, domain.GetUserStatusKey(online), domain.GetUserSessionsKey(online), domain.GetUserSessionKey(online, session.SessionID))
+	if err := client.Set(ctx, "user:last_status:"+online, string(domain.StatusOnline), time.Hour).Err(); err != nil {
+		check.failf("Error simulating the expiry: %v", err)
+	} else if _, err := repo.ExpireUserStatus(online); err != nil {
+		check.failf("Error expiring status: %v", err)
+	}
+	// An expiry no worker saw: the stats entry is past its status key's expiry
+	for shard := 0; shard < domain.PresenceStatsShards; shard++ {
+		client.ZAddXX(ctx, domain.GetPresenceStatsKey(shard, domain.StatusOnline), redis.Z{Score: float64(time.Now().Add(-time.Second).UnixMilli()), Member: silent})
+	}
+	expectDelta("After expiries", before, stats(), 2, map[domain.Status]int64{domain.StatusAway: 1, domain.StatusInvisible: 1, domain.StatusDND: 1})
+
+	for _, day := range []time.Time{time.Now().Add(48 * time.Hour), time.Now().Add(-domain.DailyActiveRetention - 24*time.Hour)} {
+		_, err := svc.PresenceStats.GetPresenceStats(day)
+		check.expect(errors.Is(err, domain.ErrInvalidInput), "Stats for %s: expected invalid input, got %v", day.Format(time.DateOnly), err)
+	}
+
+	check.summary("Counters follow status changes and expiries, daily actives counted, dates validated")
+}
+
+func runOnlineContactsTests(client *redis.Client, svc router.Services, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 32: Online contacts come from the sharded heartbeat sets, paged in a stable snapshot
+	fmt.Println("\n32. Paging through user 870's online contacts...")
+	var check checks
+
+	owner := id(870)
+	first, second, third, idle, hidden, offline, unseen := id(871), id(872), id(873), id(874), id(875), id(876), id(877)
+	contacts := []string{first, second, third, idle, hidden, offline, unseen}
+	session := domain.ClientSession{SessionID: "contacts"}
+	client.Del(ctx, domain.GetUserContactsKey(owner))
+	for _, userID := range contacts {
+		client.Del(ctx, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID), domain.GetUserSessionKey(userID, session.SessionID), "user:last_status:"+userID)
+		for shard := 0; shard < domain.PresenceStatsShards; shard++ {
+			client.ZRem(ctx, domain.GetPresenceHeartbeatsKey(shard), userID)
+		}
+	}
+	if err := svc.Contacts.AddContacts(owner, contacts); err != nil {
+		check.failf("Error adding contacts: %v", err)
+		return
+	}
+
+	// Heartbeats a few milliseconds apart make the most recent order deterministic
+	for _, userID := range []string{offline, hidden, first, second, third, idle} {
+		if _, err := svc.UserStatus.SendHeartbeat(userID, session, nil); err != nil {
+			check.failf("Error sending heartbeat for %s: %v", userID, err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := svc.UserStatus.SetUserInvisible(hidden, session); err != nil {
+		check.failf("Error setting invisible: %v", err)
+	}
+	if err := svc.UserStatus.SetUserOffline(offline, session); err != nil {
+		check.failf("Error setting offline: %v", err)
+	}
+	if err := svc.UserStatus.SetUserStatus(idle, session, domain.StatusAway); err != nil {
+		check.failf("Error setting away: %v", err)
+	}
+
+	// Each user's heartbeat lands in exactly one shard, and the contacts spread over several
+	shards := make(map[int]bool)
+	for _, userID := range []string{first, second, third, idle} {
+		var found []int
+		for shard := 0; shard < domain.PresenceStatsShards; shard++ {
+			if err := client.ZScore(ctx, domain.GetPresenceHeartbeatsKey(shard), userID).Err(); err == nil {
+				found = append(found, shard)
+				shards[shard] = true
+			}
+		}
+		check.expect(len(found) == 1, "%s recorded in heartbeat shards %v", userID, found)
+	}
+	check.expect(len(shards) > 1, "All contacts recorded in a single heartbeat shard")
+
+	var listed []string
+	var pages int
+	query := domain.OnlineContactsQuery{Limit: 2}
+	for {
+		page, next, err := svc.Contacts.GetOnlineContacts(owner, query)
+		if err != nil {
+			check.failf("Error getting page %d: %v", pages+1, err)
+			break
+		}
+		pages++
+		for _, status := range page {
+			listed = append(listed, status.UserID+"="+string(status.Status))
+		}
+		if next == "" || pages > 5 {
+			break
+		}
+		query.Cursor = next
+
+		// Activity after the first page does not reorder or repeat contacts
+		if _, err := svc.UserStatus.SendHeartbeat(first, session, nil); err != nil {
+			check.failf("Error sending heartbeat: %v", err)
+		}
+	}
+	want := []string{idle + "=away", third + "=online", second + "=online", first + "=online"}
+	check.expect(fmt.Sprint(listed) == fmt.Sprint(want) && pages == 2, "Online contacts: expected %v in 2 pages, got %v in %d", want, listed, pages)
+
+	_, _, err := svc.Contacts.GetOnlineContacts(owner, domain.OnlineContactsQuery{Cursor: "not-a-cursor", Limit: 2})
+	check.expect(errors.Is(err, domain.ErrInvalidInput), "Malformed cursor: expected invalid input, got %v", err)
+	_, _, err = svc.Contacts.GetOnlineContacts(owner, domain.OnlineContactsQuery{Limit: services.MaxOnlineContactsLimit + 1})
+	check.expect(errors.Is(err, domain.ErrInvalidInput), "Limit above the maximum: expected invalid input, got %v", err)
+
+	check.summary("Present contacts listed most recent first across pages, invisible and offline left out, heartbeats sharded")
+}
+
+func runBulkHeartbeatTests(client *redis.Client, svc router.Services, ids domain.UserIDValidator, policy domain.PresencePolicy, id func(int) string) {
+	ctx := context.Background()
+
+	// Test 33: A gateway heartbeats many sessions at once with per-user results
+	fmt.Println("\n33. Sending one bulk heartbeat for auto away, manual away, invisible and new sessions...")
+	var check checks
+
+	autoAway, manualAway, hidden, fresh := id(880), id(881), id(882), id(883)
+	session := domain.ClientSession{SessionID: "gateway"}
+	mobile := domain.ClientSession{SessionID: "gateway", DeviceType: domain.DeviceMobile}
+	for _, userID := range []string{autoAway, manualAway, hidden, fresh} {
+		client.Del(ctx, domain.GetUserStatusKey(userID), domain.GetUserSessionsKey(userID), domain.GetUserSessionKey(userID, session.SessionID), "user:last_status:"+userID)
+	}
+	if _, err := svc.UserStatus.SendHeartbeat(autoAway, session, &domain.HeartbeatActivity{IdleSeconds: 600}); err != nil {
+		check.failf("Error sending idle heartbeat: %v", err)
+	}
+	if err := svc.UserStatus.SetUserStatus(manualAway, session, domain.StatusAway); err != nil {
+		check.failf("Error setting away: %v", err)
+	}
+	if err := svc.UserStatus.SetUserInvisible(hidden, session); err != nil {
+		check.failf("Error setting invisible: %v", err)
+	}
+
+	active := &domain.HeartbeatActivity{InputActive: ptr(true)}
+	results, err := svc.UserStatus.SendBulkHeartbeat([]domain.BulkHeartbeat{
+		{UserID: autoAway, Session: session, Activity: active},
+		{UserID: "not a valid id!", Session: session},
+		{UserID: manualAway, Session: session, Activity: active},
+		{UserID: hidden, Session: session},
+		{UserID: fresh, Session: mobile},
+		{UserID: fresh, Session: domain.ClientSession{DeviceType: "fridge"}},
+	})
+	if err != nil {
+		check.failf("Error sending bulk heartbeat: %v", err)
+		return
+	}
+	view, err := svc.UserStatus.GetPresencePolicy(domain.DeviceWeb)
+	if err != nil {
+		check.failf("Error getting presence policy: %v", err)
+		return
+	}
+
+	want := []struct {
+		userID   string
+		status   domain.Status
+		interval time.Duration
+		invalid  bool
+	}{
+		{autoAway, domain.StatusOnline, policy.NextHeartbeatInterval(domain.DeviceUnknown, domain.StatusOnline, view.LoadMultiplier), false},
+		{"not a valid id!", "", 0, true},
+		{manualAway, domain.StatusAway, policy.NextHeartbeatInterval(domain.DeviceUnknown, domain.StatusAway, view.LoadMultiplier), false},
+		{hidden, domain.StatusInvisible, policy.NextHeartbeatInterval(domain.DeviceUnknown, domain.StatusInvisible, view.LoadMultiplier), false},
+		{fresh, domain.StatusOnline, policy.NextHeartbeatInterval(domain.DeviceMobile, domain.StatusOnline, view.LoadMultiplier), false},
+		{fresh, "", 0, true},
+	}
+	if !check.expect(len(results) == len(want), "Expected %d results, got %d", len(want), len(results)) {
+		return
+	}
+	for i, w := range want {
+		result := results[i]
+		if w.invalid {
+			check.expect(result.Error != "" && result.Status == "", "Entry %d (%s): expected an error, got %+v", i, w.userID, result)
+			continue
+		}
+		check.expect(result.Error == "" && result.UserID == w.userID && result.Status == w.status && result.HeartbeatInterval == w.interval,
+			"Entry %d: expected %s %s every %s, got %+v", i, w.userID, w.status, w.interval, result)
+		if status, err := svc.UserStatus.GetUserStatus(w.userID); err == nil {
+			check.expect(status.Status == w.status, "%s stored as %s, expected %s", w.userID, status.Status, w.status)
+		}
+	}
+
+	// A node's worth of heartbeats goes out in a single pipeline
+	counter := &commandCounter{}
+	counted := redis.NewClient(client.Options())
+	defer counted.Close()
+	counted.AddHook(counter)
+	gateway := services.NewUserStatusService(repository.NewRedisUserStatusRepository(counted, policy, config.NewStatusHistoryRetention()),
+		repository.NewRedisContactsRepository(counted), policy, ids, 1000)
+
+	batch := make([]domain.BulkHeartbeat, 1000)
+	for i := range batch {
+		batch[i] = domain.BulkHeartbeat{UserID: id(20000 + i), Session: session}
+	}
+	results, err = gateway.SendBulkHeartbeat(batch)
+	if err != nil {
+		check.failf("Error sending %d heartbeats: %v", len(batch), err)
+	} else {
+		var failed int
+		for _, result := range results {
+			if result.Error != "" || result.Status != domain.StatusOnline {
+				failed++
+			}
+		}
+		check.expect(failed == 0, "%d of %d heartbeats did not come back online", failed, len(batch))
+		check.expect(counter.roundTrips <= 2, "Bulk heartbeat of %d sessions took %d round trips", len(batch), counter.roundTrips)
+	}
+
+	_, err = gateway.SendBulkHeartbeat(nil)
+	check.expect(errors.Is(err, domain.ErrInvalidInput), "Empty batch: expected invalid input, got %v", err)
+	_, err = gateway.SendBulkHeartbeat(append(batch, batch[0]))
+	check.expect(errors.Is(err, domain.ErrTooManyUserIDs), "Batch above the limit: expected too many user IDs, got %v", err)
+
+	// Only gateways holding the internal token may heartbeat for others
+	gin.SetMode(gin.ReleaseMode)
+	gin.DefaultWriter = io.Discard
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/heartbeats", strings.NewReader(`{"heartbeats":[{"user_id":"`+fresh+`"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.SetupRouter(svc, "runner-internal-token").ServeHTTP(w, req)
+	check.expect(w.Code == http.StatusForbidden, "Bulk heartbeat without token: expected HTTP 403, got %d", w.Code)
+
+	check.summary("Per-user results with away→online handled per session, %d heartbeats in %d round trip(s)", len(batch), counter.roundTrips)
+}
+
+// checks counts the failed cases of one numbered test, so its ✅ summary is only
+// printed when every case matched
+type checks struct {
+	failed int
+}
+
+// failf logs a failed case
+func (c *checks) failf(format string, args ...any) {
+	log.Printf("❌ "+format, args...)
+	c.failed++
+}
+
+// expect logs a failed case unless ok, and returns ok
+func (c *checks) expect(ok bool, format string, args ...any) bool {
+	if !ok {
+		c.failf(format, args...)
+	}
+	return ok
+}
+
+// summary prints the ✅ line of the test when no case failed
+func (c *checks) summary(format string, args ...any) {
+	if c.failed == 0 {
+		fmt.Printf("✅ "+format+"\n", args...)
+	}
+}
+
+// ptr returns a pointer to a copy of v
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// testUserID builds the ID of test user n in the configured scheme and normalizes
+// it, so tests only write keys a real request could reach
+func testUserID(ids domain.UserIDValidator, scheme domain.UserIDScheme, n int) string {
+	var raw string
+	switch scheme.Kind {
+	case domain.UserIDSchemeUUID:
+		raw = fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+	case domain.UserIDSchemeSnowflake:
+		raw = strconv.Itoa(n)
+	default:
+		raw = scheme.Prefix + strconv.Itoa(n)
+	}
+
+	userID, err := ids.Normalize(raw)
+	if err != nil {
+		log.Fatalf("Test user ID %q is invalid for the configured scheme: %v", raw, err)
+	}
+	return userID
 }
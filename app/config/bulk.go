@@ -0,0 +1,16 @@
+package config
+
+import (
+	"strconv"
+
+	"social-app/internal/domain"
+)
+
+// NewBulkMaxUserIDs loads the maximum number of user IDs per bulk request from environment
+func NewBulkMaxUserIDs() int {
+	maxIDs, err := strconv.Atoi(getEnv("STATUS_BULK_MAX_IDS", strconv.Itoa(domain.DefaultBulkMaxUserIDs)))
+	if err != nil || maxIDs <= 0 {
+		maxIDs = domain.DefaultBulkMaxUserIDs
+	}
+	return maxIDs
+}
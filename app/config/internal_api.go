@@ -0,0 +1,7 @@
+package config
+
+// NewInternalAPIToken loads the shared secret internal services present in the
+// X-Internal-Token header (INTERNAL_API_TOKEN). Empty closes internal-only routes.
+func NewInternalAPIToken() string {
+	return getEnv("INTERNAL_API_TOKEN", "")
+}
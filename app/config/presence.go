@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"social-app/internal/domain"
+)
+
+// NewPresencePolicy loads the presence policy from environment, starting from the
+// built-in defaults. Unset variables keep their default value.
+//
+//	PRESENCE_ONLINE_TTL, PRESENCE_AWAY_TTL, PRESENCE_OFFLINE_TTL, PRESENCE_INVISIBLE_TTL
+//	PRESENCE_HEARTBEAT_INTERVAL, PRESENCE_HEARTBEAT_INTERVAL_{DESKTOP,WEB,MOBILE}
+//	PRESENCE_HEARTBEAT_GRACE, PRESENCE_MAX_HEARTBEAT_INTERVAL, PRESENCE_IDLE_AWAY_THRESHOLD
+//	PRESENCE_AWAY_HEARTBEAT_MULTIPLIER, PRESENCE_HEARTBEAT_LOAD_THRESHOLD, PRESENCE_MAX_LOAD_MULTIPLIER
+//	PRESENCE_TRANSITIONS (e.g. "online:away,away:offline,invisible:away,dnd:away")
+func NewPresencePolicy() (domain.PresencePolicy, error) {
+	policy := domain.DefaultPresencePolicy()
+
+	durations := map[string]*time.Duration{
+		"PRESENCE_HEARTBEAT_INTERVAL":     &policy.HeartbeatInterval,
+		"PRESENCE_HEARTBEAT_GRACE":        &policy.HeartbeatGrace,
+		"PRESENCE_MAX_HEARTBEAT_INTERVAL": &policy.MaxHeartbeat,
+		"PRESENCE_IDLE_AWAY_THRESHOLD":    &policy.IdleAwayThreshold,
+	}
+	for key, target := range durations {
+		if err := parseDurationEnv(key, target); err != nil {
+			return policy, err
+		}
+	}
+
+	floats := map[string]*float64{
+		"PRESENCE_AWAY_HEARTBEAT_MULTIPLIER": &policy.AwayMultiplier,
+		"PRESENCE_HEARTBEAT_LOAD_THRESHOLD":  &policy.LoadThreshold,
+		"PRESENCE_MAX_LOAD_MULTIPLIER":       &policy.MaxLoadMultiplier,
+	}
+	for key, target := range floats {
+		if err := parseFloatEnv(key, target); err != nil {
+			return policy, err
+		}
+	}
+
+	for _, status := range domain.Statuses {
+		if !status.IsSessionStatus() {
+			continue
+		}
+		ttl := policy.StatusTTLs[status]
+		if err := parseDurationEnv("PRESENCE_"+strings.ToUpper(status.String())+"_TTL", &ttl); err != nil {
+			return policy, err
+		}
+		policy.StatusTTLs[status] = ttl
+	}
+
+	for _, device := range []string{domain.DeviceDesktop, domain.DeviceWeb, domain.DeviceMobile} {
+		key := "PRESENCE_HEARTBEAT_INTERVAL_" + strings.ToUpper(device)
+		if os.Getenv(key) == "" {
+			continue
+		}
+		var interval time.Duration
+		if err := parseDurationEnv(key, &interval); err != nil {
+			return policy, err
+		}
+		policy.DeviceHeartbeats[device] = interval
+	}
+
+	if raw := os.Getenv("PRESENCE_TRANSITIONS"); raw != "" {
+		transitions, err := parseTransitions(raw)
+		if err != nil {
+			return policy, err
+		}
+		policy.Transitions = transitions
+	}
+
+	if err := policy.Validate(); err != nil {
+		return policy, fmt.Errorf("invalid presence policy: %w", err)
+	}
+	return policy, nil
+}
+
+// parseDurationEnv overwrites target with the duration in the given variable, if set
+func parseDurationEnv(key string, target *time.Duration) error {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return nil
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*target = value
+	return nil
+}
+
+// parseFloatEnv overwrites target with the number in the given variable, if set
+func parseFloatEnv(key string, target *float64) error {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*target = value
+	return nil
+}
+
+// parseTransitions parses a "from:to,from:to" transition table
+func parseTransitions(raw string) (map[domain.Status]domain.Status, error) {
+	transitions := make(map[domain.Status]domain.Status)
+	for _, pair := range strings.Split(raw, ",") {
+		rawFrom, rawTo, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid PRESENCE_TRANSITIONS entry %q: expected from:to", pair)
+		}
+		from, err := domain.ParseStatus(rawFrom)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PRESENCE_TRANSITIONS entry %q: %w", pair, err)
+		}
+		to, err := domain.ParseStatus(rawTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PRESENCE_TRANSITIONS entry %q: %w", pair, err)
+		}
+		transitions[from] = to
+	}
+	return transitions, nil
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"strconv"
+	"time"
+
+	"social-app/internal/domain"
+)
+
+// NewStatusHistoryRetention loads status history retention from environment
+func NewStatusHistoryRetention() domain.StatusHistoryRetention {
+	maxEntries, err := strconv.ParseInt(getEnv("STATUS_HISTORY_MAX_ENTRIES", "1000"), 10, 64)
+	if err != nil || maxEntries <= 0 {
+		maxEntries = 1000
+	}
+
+	maxAge, err := time.ParseDuration(getEnv("STATUS_HISTORY_RETENTION", "720h"))
+	if err != nil || maxAge <= 0 {
+		maxAge = 30 * 24 * time.Hour
+	}
+
+	return domain.StatusHistoryRetention{
+		MaxEntries: maxEntries,
+		MaxAge:     maxAge,
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"strconv"
+
+	"social-app/internal/domain"
+)
+
+// NewUserIDScheme loads the user ID scheme from environment
+//
+//	USER_ID_SCHEME      prefix (default), uuid, snowflake, or regex
+//	USER_ID_PREFIX      prefix for the prefix scheme (default "user_")
+//	USER_ID_PATTERN     pattern for the regex scheme
+//	USER_ID_MAX_LENGTH  maximum ID length for prefix and regex schemes (default 50)
+//	USER_ID_FOLD_CASE   treat IDs case-insensitively (default false; turning it on
+//	                    moves existing mixed-case IDs to new keys)
+func NewUserIDScheme() domain.UserIDScheme {
+	maxLength, err := strconv.Atoi(getEnv("USER_ID_MAX_LENGTH", "50"))
+	if err != nil || maxLength < 0 {
+		maxLength = 50
+	}
+
+	foldCase, err := strconv.ParseBool(getEnv("USER_ID_FOLD_CASE", "false"))
+	if err != nil {
+		foldCase = false
+	}
+
+	return domain.UserIDScheme{
+		Kind:      getEnv("USER_ID_SCHEME", domain.UserIDSchemePrefix),
+		Prefix:    getEnv("USER_ID_PREFIX", "user_"),
+		Pattern:   getEnv("USER_ID_PATTERN", ""),
+		MaxLength: maxLength,
+		FoldCase:  foldCase,
+	}
+}
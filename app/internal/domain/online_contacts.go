@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"strconv"
+	"time"
+)
+
+// PresenceHeartbeatsKeyPrefix is a ZSET per shard of user IDs scored by their last
+// heartbeat or status write (unix ms), used to find recently active contacts without
+// scanning. Users are spread over the PresenceStatsShards shards like the presence
+// stats, so no single key takes every heartbeat.
+const PresenceHeartbeatsKeyPrefix = "presence:heartbeats:"
+
+// Online contacts pagination
+const (
+	ContactsSnapshotKeyPrefix = "user:contacts_snapshot:" // user:contacts_snapshot:{user_id}:{snapshot} ZSET of listed contacts scored by last heartbeat (unix ms)
+	ContactsSnapshotTTL       = 10 * time.Minute          // How long a next_cursor stays usable
+)
+
+// ContactActivity is a contact together with their last heartbeat
+type ContactActivity struct {
+	UserID        string
+	LastHeartbeat time.Time
+}
+
+// OnlineContactsQuery selects a page of user's online contacts, most recently active first
+type OnlineContactsQuery struct {
+	Cursor string // Cursor of the last contact of the previous page (exclusive), bound to the first page's snapshot
+	Limit  int64
+}
+
+// ContactsRepository stores contact lists and finds recently active contacts
+type ContactsRepository interface {
+	AddContacts(userID string, contactIDs []string) error
+	RemoveContact(userID, contactID string) error
+	GetContacts(userID string) ([]string, error)
+	HasContacts(userID string, contactIDs []string) (map[string]bool, error)
+	GetMultipleHasContact(userIDs []string, contactID string) (map[string]bool, error)
+	GetActiveContacts(userID string, since time.Time) ([]ContactActivity, error)
+	SaveContactsSnapshot(userID, snapshot string, contacts []ContactActivity) error
+	GetContactsSnapshot(userID, snapshot string) ([]ContactActivity, error)
+}
+
+// GetPresenceHeartbeatsKey returns Redis key for the heartbeat recency set of a shard
+func GetPresenceHeartbeatsKey(shard int) string {
+	return PresenceHeartbeatsKeyPrefix + strconv.Itoa(shard)
+}
+
+// GetContactsSnapshotKey returns Redis key for the contacts snapshot a paginated listing continues from
+func GetContactsSnapshotKey(userID, snapshot string) string {
+	return ContactsSnapshotKeyPrefix + userID + ":" + snapshot
+}
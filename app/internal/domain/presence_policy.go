@@ -0,0 +1,212 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Default presence policy values
+const (
+	DefaultOnlineTTL         = 30 * time.Second
+	DefaultAwayTTL           = 10 * time.Minute
+	DefaultOfflineTTL        = 24 * time.Hour
+	DefaultHeartbeatInterval = 25 * time.Second
+	DefaultMobileHeartbeat   = 60 * time.Second // Mobile radios are expensive to wake up
+	DefaultMaxHeartbeat      = 5 * time.Minute
+	DefaultHeartbeatGrace    = 5 * time.Second
+	DefaultIdleAwayThreshold = 5 * time.Minute // Client-reported idle time after which a session is away
+	DefaultAwayHeartbeatRate = 4               // Away sessions beat this many times less often
+	DefaultLoadThreshold     = 1000            // Heartbeats per second an instance handles before backing off
+	DefaultMaxLoadMultiplier = 4
+)
+
+// PresencePolicy holds the tunable presence timings: how long each status lives,
+// how often clients send heartbeats, and what an expired status transitions to.
+type PresencePolicy struct {
+	StatusTTLs        map[Status]time.Duration
+	HeartbeatInterval time.Duration
+	DeviceHeartbeats  map[string]time.Duration // Per device type heartbeat interval overrides
+	HeartbeatGrace    time.Duration            // Lateness tolerated before a live session expires
+	MaxHeartbeat      time.Duration            // Upper bound for adapted heartbeat intervals
+	AwayMultiplier    float64                  // Interval multiplier for away sessions
+	LoadThreshold     float64                  // Heartbeats per second before intervals stretch (0 = off)
+	MaxLoadMultiplier float64                  // Upper bound for the load multiplier
+	IdleAwayThreshold time.Duration
+	Transitions       map[Status]Status // Expired status → status it transitions to
+}
+
+// PresencePolicyView is the part of the policy reported to clients
+type PresencePolicyView struct {
+	DeviceType               string            `json:"device_type"`
+	HeartbeatIntervalSeconds int64             `json:"heartbeat_interval_seconds"`
+	AwayIntervalSeconds      int64             `json:"away_heartbeat_interval_seconds"`
+	MaxIntervalSeconds       int64             `json:"max_heartbeat_interval_seconds"`
+	LoadMultiplier           float64           `json:"load_multiplier"`
+	HeartbeatGraceSeconds    int64             `json:"heartbeat_grace_seconds"`
+	IdleAwaySeconds          int64             `json:"idle_away_seconds"`
+	StatusTTLSeconds         map[Status]int64  `json:"status_ttl_seconds"`
+	Transitions              map[Status]Status `json:"transitions"`
+}
+
+// DefaultPresencePolicy returns the built-in presence policy
+func DefaultPresencePolicy() PresencePolicy {
+	return PresencePolicy{
+		StatusTTLs:        defaultStatusTTLs(),
+		HeartbeatInterval: DefaultHeartbeatInterval,
+		DeviceHeartbeats: map[string]time.Duration{
+			DeviceMobile: DefaultMobileHeartbeat,
+		},
+		HeartbeatGrace:    DefaultHeartbeatGrace,
+		MaxHeartbeat:      DefaultMaxHeartbeat,
+		AwayMultiplier:    DefaultAwayHeartbeatRate,
+		LoadThreshold:     DefaultLoadThreshold,
+		MaxLoadMultiplier: DefaultMaxLoadMultiplier,
+		IdleAwayThreshold: DefaultIdleAwayThreshold,
+		Transitions:       copyTransitions(DefaultTransitions),
+	}
+}
+
+// Validate checks that every status and transition target has a usable TTL
+func (p PresencePolicy) Validate() error {
+	if p.HeartbeatInterval <= 0 {
+		return errors.New("heartbeat interval must be positive")
+	}
+	if p.HeartbeatGrace < 0 {
+		return errors.New("heartbeat grace cannot be negative")
+	}
+	if p.MaxHeartbeat < p.HeartbeatInterval {
+		return errors.New("max heartbeat interval cannot be below the heartbeat interval")
+	}
+	if p.AwayMultiplier < 1 || p.MaxLoadMultiplier < 1 {
+		return errors.New("heartbeat multipliers must be at least 1")
+	}
+	if p.LoadThreshold < 0 {
+		return errors.New("load threshold cannot be negative")
+	}
+	if p.IdleAwayThreshold <= 0 {
+		return errors.New("idle away threshold must be positive")
+	}
+	for device, interval := range p.DeviceHeartbeats {
+		if interval <= 0 {
+			return fmt.Errorf("heartbeat interval for %s must be positive", device)
+		}
+	}
+	for _, status := range Statuses {
+		if status.IsSessionStatus() && p.StatusTTLs[status] <= 0 {
+			return fmt.Errorf("TTL for %s must be positive", status)
+		}
+	}
+	for status, ttl := range p.StatusTTLs {
+		if ttl <= StatusExpiryLockTTL {
+			return fmt.Errorf("TTL for %s must be longer than the %s expiry lock", status, StatusExpiryLockTTL)
+		}
+	}
+	for from, to := range p.Transitions {
+		if !from.IsValid() || !to.IsValid() {
+			return fmt.Errorf("invalid transition %s → %s", from, to)
+		}
+		if p.StatusTTLs[to] <= 0 {
+			return fmt.Errorf("transition target %s has no TTL", to)
+		}
+	}
+	return nil
+}
+
+// HeartbeatIntervalFor returns the base interval a client of the given device type sends heartbeats at
+func (p PresencePolicy) HeartbeatIntervalFor(deviceType string) time.Duration {
+	if interval, ok := p.DeviceHeartbeats[deviceType]; ok {
+		return interval
+	}
+	return p.HeartbeatInterval
+}
+
+// NextHeartbeatInterval adapts the base interval of a device type to the session's
+// status and the server load multiplier, capped at MaxHeartbeat
+func (p PresencePolicy) NextHeartbeatInterval(deviceType string, status Status, load float64) time.Duration {
+	factor := 1.0
+	if status.IsIdle() {
+		factor = p.AwayMultiplier
+	}
+	if load > 1 {
+		factor *= load
+	}
+
+	interval := time.Duration(float64(p.HeartbeatIntervalFor(deviceType)) * factor)
+	if interval > p.MaxHeartbeat {
+		interval = p.MaxHeartbeat
+	}
+	return interval.Truncate(time.Second)
+}
+
+// LoadMultiplier converts a heartbeat rate (per second) into a multiplier ≥ 1
+// that stretches heartbeat intervals once the rate exceeds LoadThreshold
+func (p PresencePolicy) LoadMultiplier(rate float64) float64 {
+	if p.LoadThreshold <= 0 || rate <= p.LoadThreshold {
+		return 1
+	}
+	return math.Min(rate/p.LoadThreshold, p.MaxLoadMultiplier)
+}
+
+// StatusTTL returns how long a status lives without being refreshed
+func (p PresencePolicy) StatusTTL(status Status) time.Duration {
+	return p.StatusTTLs[status]
+}
+
+// LiveWindow is how long after their last heartbeat a user can still be present:
+// the longest chain of status lifetimes and expiry transitions before offline
+func (p PresencePolicy) LiveWindow() time.Duration {
+	heartbeatTTL := p.MaxHeartbeat + p.HeartbeatGrace
+
+	var window time.Duration
+	for _, start := range Statuses {
+		var total time.Duration
+		seen := make(map[Status]bool)
+		for status := start; status.IsPresent() && !seen[status]; status = p.Transitions[status] {
+			seen[status] = true
+			total += max(p.StatusTTL(status), heartbeatTTL)
+		}
+		window = max(window, total)
+	}
+	return window
+}
+
+// SessionTTL returns the TTL of a session status set from the given device type.
+// A session lives at least until its next expected heartbeat plus grace, so
+// adapted intervals never let presence expire between beats.
+func (p PresencePolicy) SessionTTL(status Status, deviceType string, load float64) time.Duration {
+	ttl := p.StatusTTL(status)
+	if live := p.NextHeartbeatInterval(deviceType, status, load) + p.HeartbeatGrace; live > ttl {
+		ttl = live
+	}
+	return ttl
+}
+
+// View returns the policy as reported to a client of the given device type
+func (p PresencePolicy) View(deviceType string, load float64) PresencePolicyView {
+	ttls := make(map[Status]int64, len(p.StatusTTLs))
+	for status := range p.StatusTTLs {
+		ttls[status] = int64(p.SessionTTL(status, deviceType, load).Seconds())
+	}
+	return PresencePolicyView{
+		DeviceType:               deviceType,
+		HeartbeatIntervalSeconds: int64(p.NextHeartbeatInterval(deviceType, StatusOnline, load).Seconds()),
+		AwayIntervalSeconds:      int64(p.NextHeartbeatInterval(deviceType, StatusAway, load).Seconds()),
+		MaxIntervalSeconds:       int64(p.MaxHeartbeat.Seconds()),
+		LoadMultiplier:           load,
+		HeartbeatGraceSeconds:    int64(p.HeartbeatGrace.Seconds()),
+		IdleAwaySeconds:          int64(p.IdleAwayThreshold.Seconds()),
+		StatusTTLSeconds:         ttls,
+		Transitions:              copyTransitions(p.Transitions),
+	}
+}
+
+// copyTransitions returns a copy of a transition table
+func copyTransitions(transitions map[Status]Status) map[Status]Status {
+	copied := make(map[Status]Status, len(transitions))
+	for from, to := range transitions {
+		copied[from] = to
+	}
+	return copied
+}
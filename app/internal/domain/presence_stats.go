@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"strconv"
+	"time"
+)
+
+// Redis key patterns for presence statistics
+const (
+	PresenceStatsKeyPrefix = "stats:presence:"     // stats:presence:{shard}:{status} ZSET of user IDs scored by status expiry (unix ms)
+	DailyActiveKeyPrefix   = "stats:daily_active:" // stats:daily_active:{YYYY-MM-DD} HyperLogLog of active user IDs (UTC day)
+	PresenceStatsShards    = 16                    // Spreads the status sets so no single key gets every write
+	DailyActiveRetention   = 31 * 24 * time.Hour   // How long daily active counts can be queried
+)
+
+// PresenceStats is a live snapshot of how many users are present
+type PresenceStats struct {
+	Online      int64            `json:"online"`       // Users others see as present (anything but offline and invisible)
+	Counts      map[Status]int64 `json:"counts"`       // Users per shown status (DND included), invisible reported separately
+	Date        string           `json:"date"`         // UTC day DailyActive refers to
+	DailyActive int64            `json:"daily_active"` // Approximate unique active users that day
+	Timestamp   time.Time        `json:"timestamp"`
+}
+
+// PresenceStatsRepository reads the presence statistics the status scripts maintain
+type PresenceStatsRepository interface {
+	GetStatusCounts() (map[Status]int64, error)
+	CountDailyActive(day time.Time) (int64, error)
+}
+
+// GetPresenceStatsKey returns Redis key for the users of a status in a stats shard
+func GetPresenceStatsKey(shard int, status Status) string {
+	return PresenceStatsKeyPrefix + strconv.Itoa(shard) + ":" + string(status)
+}
+
+// GetDailyActiveKey returns Redis key for the unique active users of a UTC day
+func GetDailyActiveKey(day time.Time) string {
+	return DailyActiveKeyPrefix + day.UTC().Format(time.DateOnly)
+}
@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Redis key pattern for quiet hours schedules
+const QuietHoursKeyPrefix = "user:quiet_hours:"
+
+// QuietHoursDays maps schedule day names to time.Weekday
+var QuietHoursDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// QuietHoursWindow is a weekly recurring window in the schedule's time zone.
+// A window whose end is before its start runs past midnight into the next day.
+type QuietHoursWindow struct {
+	Days  []string `json:"days"`  // mon, tue, wed, thu, fri, sat, sun
+	Start string   `json:"start"` // HH:MM
+	End   string   `json:"end"`   // HH:MM
+}
+
+// QuietHoursSchedule represents user's recurring quiet hours during which
+// the user is reported as DND automatically
+type QuietHoursSchedule struct {
+	UserID    string             `json:"user_id"`
+	TimeZone  string             `json:"time_zone"`
+	Enabled   bool               `json:"enabled"`
+	Windows   []QuietHoursWindow `json:"windows"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// GetQuietHoursKey returns Redis key for user's quiet hours schedule
+func GetQuietHoursKey(userID string) string {
+	return QuietHoursKeyPrefix + userID
+}
+
+// ActiveUntil returns the end of the window running at now, or nil if none is.
+// Windows follow the wall clock of the schedule's time zone and run from their start
+// to the first occurrence of their end after it: an end skipped by a DST jump ends
+// the window at the jump, and an end repeated when clocks fall back ends it the
+// first time, so the repeated hour does not enter the window again.
+func (q *QuietHoursSchedule) ActiveUntil(now time.Time) *time.Time {
+	if q == nil || !q.Enabled {
+		return nil
+	}
+
+	loc, err := LoadLocation(q.TimeZone)
+	if err != nil {
+		return nil
+	}
+
+	local := now.In(loc)
+	// A window that started yesterday may still be running past midnight
+	startDays := []time.Time{local.AddDate(0, 0, -1), local}
+
+	for _, window := range q.Windows {
+		start, errStart := ParseClock(window.Start)
+		end, errEnd := ParseClock(window.End)
+		if errStart != nil || errEnd != nil {
+			continue
+		}
+
+		for _, startDay := range startDays {
+			if !window.hasDay(startDay.Weekday()) {
+				continue
+			}
+			endDay := startDay
+			if end < start {
+				endDay = startDay.AddDate(0, 0, 1)
+			}
+
+			from := wallClock(startDay, start, loc)
+			until := wallClock(endDay, end, loc)
+			if !now.Before(from) && now.Before(until) {
+				return &until
+			}
+		}
+	}
+
+	return nil
+}
+
+// wallClock returns when the wall clock in loc first shows minute (since midnight) on
+// day. A time skipped by a DST jump resolves to the jump; Date already resolves a time
+// repeated when clocks fall back to its first occurrence.
+func wallClock(day time.Time, minute int, loc *time.Location) time.Time {
+	t := time.Date(day.Year(), day.Month(), day.Day(), minute/60, minute%60, 0, 0, loc)
+	if wall := t.Hour()*60 + t.Minute(); wall != minute {
+		// Date resolves the gap to either side of the jump
+		zoneStart, zoneEnd := t.ZoneBounds()
+		if wall < minute {
+			return zoneEnd
+		}
+		return zoneStart
+	}
+	return t
+}
+
+// locations caches loaded time zones, since LoadLocation reads the zone database on every call
+var locations sync.Map
+
+// LoadLocation is time.LoadLocation with a cache. Only zones that exist are cached,
+// so the cache is bounded by the zone database.
+func LoadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// hasDay reports whether the window runs on the given weekday
+func (w QuietHoursWindow) hasDay(weekday time.Weekday) bool {
+	for _, day := range w.Days {
+		if QuietHoursDays[day] == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseClock parses HH:MM into minutes since midnight
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time: %s (must be HH:MM)", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Redis key patterns for room presence
+const (
+	RoomMembersKeyPrefix = "room:members:" // SET of user IDs present in a room
+	UserRoomsKeyPrefix   = "user:rooms:"   // SET of room IDs a user is present in
+)
+
+// ErrRoomFull is returned when a room already holds the maximum number of present members
+var ErrRoomFull = errors.New("room is full")
+
+// RoomPresence represents who is currently present in a room (channel, group chat).
+// Members ride on the user heartbeat lifecycle: once their status goes offline or
+// expires they drop out of the room.
+type RoomPresence struct {
+	RoomID  string         `json:"room_id"`
+	Members []*UserStatus  `json:"members,omitempty"`
+	Online  int            `json:"online"` // Members visible as present to the viewer
+	Counts  map[Status]int `json:"counts"` // Visible members per status
+}
+
+// RoomRepository stores which users are present in which rooms
+type RoomRepository interface {
+	JoinRoom(roomID, userID string, maxMembers int, ttl time.Duration) error
+	LeaveRoom(roomID, userID string) error
+	GetRoomMembers(roomID string) ([]string, error)
+	RemoveRoomMembers(roomID string, userIDs []string) error
+	LeaveAllRooms(userID string) error
+}
+
+// GetRoomMembersKey returns Redis key for the set of users present in a room
+func GetRoomMembersKey(roomID string) string {
+	return RoomMembersKeyPrefix + roomID
+}
+
+// GetUserRoomsKey returns Redis key for the set of rooms a user is present in
+func GetUserRoomsKey(userID string) string {
+	return UserRoomsKeyPrefix + userID
+}
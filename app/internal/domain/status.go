@@ -0,0 +1,212 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Status is a user presence status
+type Status string
+
+// User status constants
+const (
+	StatusOnline    Status = "online"
+	StatusAway      Status = "away"
+	StatusOffline   Status = "offline"
+	StatusInvisible Status = "invisible"
+	StatusDND       Status = "dnd"
+	StatusUnknown   Status = "unknown" // No presence recorded (never seen or fully expired)
+)
+
+// activity is the role a status plays when derived from client activity
+type activity int
+
+const (
+	activityNone   activity = iota // Never derived from activity
+	activityActive                 // The user is interacting with a client
+	activityIdle                   // The user has been idle past the idle threshold
+)
+
+// statusInfo describes how a status behaves across all layers
+type statusInfo struct {
+	precedence int           // Rank for aggregation across sessions
+	session    bool          // Stored per client session; otherwise a user-level overlay
+	manual     bool          // Choosing it explicitly pins it against heartbeats
+	live       bool          // Kept alive by heartbeats
+	present    bool          // Counts as present: can join rooms and type, shows in online contacts
+	activity   activity      // Role when derived from client activity
+	dnd        bool          // Shown as DND while do-not-disturb or quiet hours are active
+	shownAs    Status        // What other users see instead ("" = the status itself)
+	ttl        time.Duration // Default lifetime without refresh (0 = no TTL of its own)
+	transition Status        // Default status it turns into when it expires ("" = unknown)
+}
+
+// statuses is the single place statuses are defined. Invisible is a privacy
+// choice, so it wins over every other status during aggregation.
+var statuses = map[Status]statusInfo{
+	StatusInvisible: {precedence: 5, session: true, manual: true, live: true, present: true, shownAs: StatusOffline, ttl: DefaultOnlineTTL, transition: StatusAway}, // Same as online but appears offline
+	StatusOnline:    {precedence: 4, session: true, live: true, present: true, activity: activityActive, dnd: true, ttl: DefaultOnlineTTL, transition: StatusAway},
+	StatusDND:       {precedence: 3, present: true, transition: StatusAway}, // Ends at its own deadline; they were active
+	StatusAway:      {precedence: 2, session: true, manual: true, present: true, activity: activityIdle, dnd: true, ttl: DefaultAwayTTL, transition: StatusOffline},
+	StatusOffline:   {precedence: 1, session: true, ttl: DefaultOfflineTTL},
+}
+
+// Statuses lists every status a user can set, ordered by precedence
+var Statuses = func() []Status {
+	list := make([]Status, 0, len(statuses))
+	for status := range statuses {
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return statuses[list[i]].precedence > statuses[list[j]].precedence
+	})
+	return list
+}()
+
+// StatusPrecedence ranks statuses for aggregation across sessions
+var StatusPrecedence = func() map[Status]int {
+	precedence := make(map[Status]int, len(statuses))
+	for status, info := range statuses {
+		precedence[status] = info.precedence
+	}
+	return precedence
+}()
+
+// DefaultTransitions maps a status whose key expired to the status it transitions to.
+// Statuses not listed (offline) become unknown.
+var DefaultTransitions = func() map[Status]Status {
+	transitions := make(map[Status]Status, len(statuses))
+	for status, info := range statuses {
+		if info.transition != "" {
+			transitions[status] = info.transition
+		}
+	}
+	return transitions
+}()
+
+// defaultStatusTTLs returns the default lifetime of every status that has one
+func defaultStatusTTLs() map[Status]time.Duration {
+	ttls := make(map[Status]time.Duration, len(statuses))
+	for status, info := range statuses {
+		if info.ttl > 0 {
+			ttls[status] = info.ttl
+		}
+	}
+	return ttls
+}
+
+// ParseStatus parses a status a user may set
+func ParseStatus(value string) (Status, error) {
+	status := Status(strings.ToLower(strings.TrimSpace(value)))
+	if !status.IsValid() {
+		return "", fmt.Errorf("invalid status %q: must be one of %s", value, statusList())
+	}
+	return status, nil
+}
+
+// IsValid reports whether the status is one a user may set
+func (s Status) IsValid() bool {
+	_, ok := statuses[s]
+	return ok
+}
+
+// IsSessionStatus reports whether the status is stored per client session
+// (as opposed to a user-level overlay such as DND)
+func (s Status) IsSessionStatus() bool {
+	return statuses[s].session
+}
+
+// IsManual reports whether choosing the status explicitly pins it against heartbeats.
+// Choosing online or offline returns to automatic detection.
+func (s Status) IsManual() bool {
+	return statuses[s].manual
+}
+
+// IsLive reports whether the status must be kept alive by heartbeats
+func (s Status) IsLive() bool {
+	return statuses[s].live
+}
+
+// IsPresent reports whether a user with the status counts as present.
+// Present users can join rooms and type, and show up in online contacts.
+func (s Status) IsPresent() bool {
+	return statuses[s].present
+}
+
+// IsActivity reports whether heartbeats derive the status from client activity,
+// so automatic sessions holding it follow the client's activity
+func (s Status) IsActivity() bool {
+	return statuses[s].activity != activityNone
+}
+
+// IsIdle reports whether the status means the user is idle at their client
+func (s Status) IsIdle() bool {
+	return statuses[s].activity == activityIdle
+}
+
+// ShowsDND reports whether do-not-disturb and quiet hours show over the status
+func (s Status) ShowsDND() bool {
+	return statuses[s].dnd
+}
+
+// Public returns what other users see for the status (invisible appears offline)
+func (s Status) Public() Status {
+	if shown := statuses[s].shownAs; shown != "" {
+		return shown
+	}
+	return s
+}
+
+// ActivityStatus returns the status heartbeats derive from client activity
+func ActivityStatus(idle bool) Status {
+	want := activityActive
+	if idle {
+		want = activityIdle
+	}
+	for _, status := range Statuses {
+		if statuses[status].activity == want {
+			return status
+		}
+	}
+	return StatusUnknown
+}
+
+// Precedence returns the aggregation rank of the status (0 for unknown)
+func (s Status) Precedence() int {
+	return statuses[s].precedence
+}
+
+func (s Status) String() string {
+	return string(s)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. Besides settable statuses
+// it accepts unknown, which is what reads report for users without presence.
+func (s *Status) UnmarshalText(text []byte) error {
+	if Status(text) == StatusUnknown {
+		*s = StatusUnknown
+		return nil
+	}
+	status, err := ParseStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// statusList renders the settable statuses for error messages
+func statusList() string {
+	names := make([]string, len(Statuses))
+	for i, status := range Statuses {
+		names[i] = string(status)
+	}
+	return strings.Join(names, ", ")
+}
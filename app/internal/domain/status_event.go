@@ -0,0 +1,80 @@
+package domain
+
+import "time"
+
+// Status change causes
+const (
+	CauseExplicit       = "explicit"        // Status set explicitly by the user
+	CauseHeartbeat      = "heartbeat"       // Heartbeat brought a session (back) online
+	CauseSessionExpired = "session_expired" // A session expired and the aggregate changed
+	CauseExpired        = "expired"         // Status key expired and was auto-transitioned
+	CauseLogout         = "logout"          // A session was ended
+	CauseDNDStarted     = "dnd_started"     // Do not disturb started
+	CauseDNDCleared     = "dnd_cleared"     // Do not disturb was ended before its deadline
+	CauseDNDEnded       = "dnd_ended"       // Do not disturb reached its deadline
+)
+
+// StatusHistoryKeyPrefix is the per-user stream of status changes
+const StatusHistoryKeyPrefix = "user:status_history:"
+
+// StatusEventsChannel is the Redis Pub/Sub channel status changes are published to
+const StatusEventsChannel = "events:user_status"
+
+// StatusExpiryLockKeyPrefix guards eager transitions so only one instance handles an expiry
+const StatusExpiryLockKeyPrefix = "lock:status_expiry:"
+
+// StatusExpiryLockTTL bounds how long a crashed instance can hold an expiry lock.
+// Every status TTL must be longer, so the next expiry of a user is never dropped.
+const StatusExpiryLockTTL = 10 * time.Second
+
+// StatusEvent represents a change of a user's effective status
+type StatusEvent struct {
+	UserID    string    `json:"user_id"`
+	OldStatus Status    `json:"old_status"`
+	NewStatus Status    `json:"new_status"`
+	Cause     string    `json:"cause"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PresenceExpiry is a presence key Redis expired: a user's status key, or their
+// do-not-disturb key when the period reached its deadline
+type PresenceExpiry struct {
+	UserID string
+	DND    bool
+}
+
+// StatusHistoryEntry is a single change recorded in the user's history stream: a
+// change of the actual status, or of what others see when DND starts or ends
+type StatusHistoryEntry struct {
+	ID          string    `json:"id"`
+	OldStatus   Status    `json:"old_status"`
+	NewStatus   Status    `json:"new_status"`
+	ShownStatus Status    `json:"shown_status"` // What others saw after the change (e.g. dnd over online)
+	Cause       string    `json:"cause"`
+	SessionID   string    `json:"session_id,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// StatusHistoryQuery selects a page of status history, newest first
+type StatusHistoryQuery struct {
+	Cursor string     // Entry ID to continue after (exclusive)
+	From   *time.Time // Oldest change to include
+	To     *time.Time // Newest change to include
+	Limit  int64
+}
+
+// StatusHistoryRetention caps how much status history is kept per user
+type StatusHistoryRetention struct {
+	MaxEntries int64
+	MaxAge     time.Duration
+}
+
+// GetStatusHistoryKey returns Redis key for user's status history stream
+func GetStatusHistoryKey(userID string) string {
+	return StatusHistoryKeyPrefix + userID
+}
+
+// GetStatusExpiryLockKey returns Redis key for the expiry transition lock of a user
+func GetStatusExpiryLockKey(userID string) string {
+	return StatusExpiryLockKeyPrefix + userID
+}
@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// Typing indicators are low-priority presence: short-lived and never persisted
+const (
+	TypingKeyPrefix           = "typing:"        // ZSET of typing user IDs scored by expiry (unix ms)
+	TypingEventsChannelPrefix = "events:typing:" // Redis Pub/Sub channel per conversation typing changes are published to
+	TypingTTL                 = 5 * time.Second  // Indicator disappears unless the client repeats it
+)
+
+// TypingEvent is published when a user starts or stops typing in a conversation.
+// Subscribers deliver it to the other conversation members, honoring VisibleTo and
+// HiddenFrom, which carry the typing user's visibility rules.
+type TypingEvent struct {
+	ConversationID string    `json:"conversation_id"`
+	UserID         string    `json:"user_id"`
+	Typing         bool      `json:"typing"`
+	ExpiresInMs    int64     `json:"expires_in_ms,omitempty"` // Hide the indicator after this unless repeated
+	VisibleTo      []string  `json:"visible_to,omitempty"`    // When set, only these members may receive the event
+	HiddenFrom     []string  `json:"hidden_from,omitempty"`   // Members the event must not be delivered to
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// TypingState lists the users currently typing in a conversation
+type TypingState struct {
+	ConversationID string   `json:"conversation_id"`
+	UserIDs        []string `json:"user_ids"`
+}
+
+// TypingRepository stores typing indicators and publishes their changes
+type TypingRepository interface {
+	StartTyping(conversationID, userID string, ttl time.Duration) error
+	StopTyping(conversationID, userID string) (bool, error)
+	GetTypingUsers(conversationID string) ([]string, error)
+	PublishTypingEvent(event TypingEvent) error
+}
+
+// GetTypingKey returns Redis key for the users typing in a conversation
+func GetTypingKey(conversationID string) string {
+	return TypingKeyPrefix + conversationID
+}
+
+// GetTypingEventsChannel returns the Pub/Sub channel typing changes of a conversation are published to
+func GetTypingEventsChannel(conversationID string) string {
+	return TypingEventsChannelPrefix + conversationID
+}
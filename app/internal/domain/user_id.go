@@ -0,0 +1,24 @@
+package domain
+
+// User ID schemes supported by the ID validator
+const (
+	UserIDSchemePrefix    = "prefix"    // Fixed prefix, e.g. user_123
+	UserIDSchemeUUID      = "uuid"      // RFC 4122 UUIDs issued by the identity service
+	UserIDSchemeSnowflake = "snowflake" // Numeric 64-bit snowflake IDs
+	UserIDSchemeRegex     = "regex"     // Custom pattern per tenant
+)
+
+// UserIDScheme configures how user IDs are validated and normalized
+type UserIDScheme struct {
+	Kind      string
+	Prefix    string // Prefix scheme only
+	Pattern   string // Regex scheme only; anchored to the whole ID
+	MaxLength int
+	FoldCase  bool // Treat IDs case-insensitively (prefix and regex schemes; UUIDs always fold)
+}
+
+// UserIDValidator validates user IDs and returns them in canonical form, so the
+// same user always maps to the same Redis keys
+type UserIDValidator interface {
+	Normalize(userID string) (string, error)
+}
@@ -1,32 +1,193 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
-// User status constants
+// Device type constants for client sessions
 const (
-	StatusOnline    = "online"
-	StatusAway      = "away"
-	StatusOffline   = "offline"
-	StatusInvisible = "invisible"
-	StatusDND       = "dnd"
-	StatusUnknown   = "unknown"
+	DeviceDesktop = "desktop"
+	DeviceWeb     = "web"
+	DeviceMobile  = "mobile"
+	DeviceUnknown = "unknown"
 )
 
-// Redis key patterns and TTL values
+// Status sources
+const (
+	SourceAuto   = "auto"   // Derived from activity (heartbeats, expiry)
+	SourceManual = "manual" // Chosen by the user; heartbeats do not override it
+)
+
+// DefaultSessionID is used when a client does not identify its session
+const DefaultSessionID = "default"
+
+// AnyVersion disables the presence version check of compare-and-set writes
+const AnyVersion int64 = -1
+
+// ErrVersionConflict is returned when a conditional write finds a different presence version
+var ErrVersionConflict = errors.New("status was changed by another client")
+
+// ErrUserNotPresent is returned when an action needs a present user (joining a room,
+// typing) but the user is offline or unknown
+var ErrUserNotPresent = errors.New("user is not online")
+
+// ErrTooManyUserIDs is returned when a bulk request exceeds the configured batch size
+var ErrTooManyUserIDs = errors.New("too many user IDs in one request")
+
+// ErrInvalidInput matches every error caused by the request itself (malformed IDs,
+// values out of range, bad cursors), as opposed to storage failures
+var ErrInvalidInput = errors.New("invalid input")
+
+// InvalidInput marks err as caused by the request. The message stays err's own.
+func InvalidInput(err error) error {
+	if err == nil {
+		return nil
+	}
+	return invalidInputError{err}
+}
+
+type invalidInputError struct {
+	err error
+}
+
+func (e invalidInputError) Error() string {
+	return e.err.Error()
+}
+
+func (e invalidInputError) Unwrap() []error {
+	return []error{ErrInvalidInput, e.err}
+}
+
+// DefaultBulkMaxUserIDs is the default batch size of bulk status requests
+const DefaultBulkMaxUserIDs = 2000
+
+// Redis key patterns and TTL values. Status TTLs are part of the PresencePolicy.
 const (
-	UserStatusKeyPrefix = "user:status:"
-	OnlineTTL           = 30 * time.Second
-	AwayTTL             = 10 * time.Minute
-	OfflineTTL          = 24 * time.Hour
+	UserStatusKeyPrefix        = "user:status:"
+	UserSessionKeyPrefix       = "user:session:"
+	UserSessionsKeyPrefix      = "user:sessions:"
+	UserLastSeenKeyPrefix      = "user:last_seen:"
+	CustomStatusKeyPrefix      = "user:custom_status:"
+	UserDNDKeyPrefix           = "user:dnd:"
+	UserStatusVersionKeyPrefix = "user:status_version:"
+	UserPrivacyKeyPrefix       = "user:privacy:"
+	UserContactsKeyPrefix      = "user:contacts:"
+	LastSeenTTL                = 90 * 24 * time.Hour // Outlives status keys so offline users keep "last seen"
 )
 
 // UserStatus represents user online/offline status
 type UserStatus struct {
-	UserID       string    `json:"user_id"`
-	Status       string    `json:"status"`
-	ActualStatus string    `json:"actual_status,omitempty"` // For invisible mode
-	Timestamp    time.Time `json:"timestamp"`
-	LastActivity time.Time `json:"last_activity,omitempty"`
+	UserID       string            `json:"user_id"`
+	Status       Status            `json:"status"`
+	ActualStatus Status            `json:"actual_status,omitempty"` // For invisible mode
+	Timestamp    time.Time         `json:"timestamp"`
+	LastActivity *time.Time        `json:"last_activity,omitempty"`
+	CustomStatus *CustomStatus     `json:"custom_status,omitempty"`
+	DNDUntil     *time.Time        `json:"dnd_until,omitempty"`
+	Sessions     []SessionPresence `json:"sessions,omitempty"`
+	Version      int64             `json:"version,omitempty"` // Presence version for If-Match
+}
+
+// CustomStatus is a user-defined status message shown next to the presence status.
+// It expires independently of presence TTLs.
+type CustomStatus struct {
+	Text      string     `json:"text,omitempty"`
+	Emoji     string     `json:"emoji,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// SessionPresence represents presence of a single client session (device, tab)
+type SessionPresence struct {
+	SessionID     string    `json:"session_id"`
+	DeviceType    string    `json:"device_type"`
+	Status        Status    `json:"status"`
+	Source        string    `json:"source"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+}
+
+// DNDState represents a do-not-disturb period chosen by the user. It overlays the
+// automatic presence status, so heartbeats and disconnects do not end it.
+type DNDState struct {
+	Since time.Time  `json:"since"`
+	Until *time.Time `json:"until,omitempty"` // nil = until cleared
+}
+
+// Active reports whether the DND period is still running at the given time
+func (d *DNDState) Active(now time.Time) bool {
+	return d != nil && (d.Until == nil || now.Before(*d.Until))
+}
+
+// LastSeen represents when a user was last active, for "last seen X ago" displays
+type LastSeen struct {
+	UserID     string     `json:"user_id"`
+	Status     Status     `json:"status"`
+	LastSeen   *time.Time `json:"last_seen,omitempty"`
+	SecondsAgo int64      `json:"seconds_ago,omitempty"`
+}
+
+// BulkStatusResult is the outcome of a bulk status lookup. Statuses are keyed by
+// normalized user ID; Errors maps each rejected input ID to the reason it was skipped.
+type BulkStatusResult struct {
+	Statuses map[string]*UserStatus
+	Errors   map[string]string
+}
+
+// HeartbeatActivity is client-reported user activity sent with a heartbeat.
+// Nil fields were not reported by the client.
+type HeartbeatActivity struct {
+	IdleSeconds int64
+	Foreground  *bool
+	InputActive *bool
+}
+
+// HeartbeatResult is the outcome of a heartbeat: the effective user status and
+// how often the client should keep sending heartbeats
+type HeartbeatResult struct {
+	Status            Status
+	HeartbeatInterval time.Duration
+}
+
+// SessionRefresh is a single session heartbeat of a bulk refresh: the status the
+// session reports, how long the user has been idle and the new session TTL
+type SessionRefresh struct {
+	UserID  string
+	Session ClientSession
+	Status  Status
+	Idle    time.Duration
+	TTL     time.Duration
+}
+
+// SessionRefreshResult is the session's status and the effective user status after a
+// SessionRefresh, or why it failed
+type SessionRefreshResult struct {
+	SessionStatus Status
+	Status        Status
+	Err           error
+}
+
+// BulkHeartbeat is one user session kept alive by a bulk heartbeat (e.g. from a connection gateway)
+type BulkHeartbeat struct {
+	UserID   string
+	Session  ClientSession
+	Activity *HeartbeatActivity
+}
+
+// BulkHeartbeatResult is the outcome of one BulkHeartbeat. Error is set instead of
+// Status when that heartbeat was rejected or failed.
+type BulkHeartbeatResult struct {
+	UserID            string
+	SessionID         string
+	Status            Status
+	HeartbeatInterval time.Duration
+	Error             string
+}
+
+// ClientSession identifies the client session a request was made from
+type ClientSession struct {
+	SessionID  string
+	DeviceType string
 }
 
 // NotificationPreference represents user's notification settings
@@ -38,15 +199,135 @@ type NotificationPreference struct {
 	DNDUntil            *time.Time `json:"dnd_until,omitempty"`
 }
 
+// Audience decides who may see a piece of user's presence
+type Audience string
+
+// Audience constants
+const (
+	AudienceEveryone Audience = "everyone"
+	AudienceContacts Audience = "contacts" // Users in the owner's contact list
+	AudienceNobody   Audience = "nobody"
+)
+
+// IsValid reports whether the audience is a known one
+func (a Audience) IsValid() bool {
+	switch a {
+	case AudienceEveryone, AudienceContacts, AudienceNobody:
+		return true
+	}
+	return false
+}
+
+// Allows reports whether a viewer is in the audience, given whether the
+// viewer is in the owner's contact list
+func (a Audience) Allows(isContact bool) bool {
+	switch a {
+	case AudienceContacts:
+		return isContact
+	case AudienceNobody:
+		return false
+	}
+	return true
+}
+
+// PrivacySettings represents user's presence privacy settings
+type PrivacySettings struct {
+	UserID    string    `json:"user_id"`
+	LastSeen  Audience  `json:"last_seen"` // Who sees last activity; reciprocal
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LastSeenAudience returns who may see the user's last seen (everyone when not configured)
+func (p *PrivacySettings) LastSeenAudience() Audience {
+	if p == nil || p.LastSeen == "" {
+		return AudienceEveryone
+	}
+	return p.LastSeen
+}
+
 // UserStatusRepository interface for Redis operations
 type UserStatusRepository interface {
-	SetUserStatus(userID, status string, ttl time.Duration) error
-	GetUserStatus(userID string) (string, error)
-	GetMultipleUserStatus(userIDs []string) (map[string]string, error)
+	SetUserStatus(userID string, status Status, ttl time.Duration) error
+	GetUserStatus(userID string) (Status, error)
+	GetUserStatusVersion(userID string) (Status, int64, error)
+	GetMultipleUserStatus(userIDs []string) (map[string]Status, error)
 	RefreshUserStatusTTL(userID string, ttl time.Duration) error
+	SetSessionStatus(userID string, session SessionPresence, ttl time.Duration) error
+	CompareAndSetSessionStatus(userID string, session SessionPresence, ttl time.Duration, version int64) (int64, error)
+	RefreshSessionTTL(userID string, session ClientSession, status Status, idle time.Duration, ttl time.Duration) (Status, Status, error)
+	RefreshSessionTTLs(refreshes []SessionRefresh) ([]SessionRefreshResult, error)
+	GetUserSessions(userID string) ([]SessionPresence, error)
+	EndSession(userID, sessionID string) error
+	GetLastSeen(userID string) (*time.Time, error)
+	GetMultipleLastSeen(userIDs []string) (map[string]*time.Time, error)
+	SetCustomStatus(userID string, custom CustomStatus, ttl time.Duration) error
+	GetCustomStatus(userID string) (*CustomStatus, error)
+	GetMultipleCustomStatus(userIDs []string) (map[string]*CustomStatus, error)
+	ClearCustomStatus(userID string) error
+	SetDND(userID string, dnd DNDState) error
+	CompareAndSetDND(userID string, dnd DNDState, version int64) (int64, error)
+	GetDND(userID string) (*DNDState, error)
+	GetMultipleDND(userIDs []string) (map[string]*DNDState, error)
+	ClearDND(userID string) error
+	SetQuietHours(userID string, schedule QuietHoursSchedule) error
+	GetQuietHours(userID string) (*QuietHoursSchedule, error)
+	GetMultipleQuietHours(userIDs []string) (map[string]*QuietHoursSchedule, error)
+	DeleteQuietHours(userID string) error
+	SetVisibilityRules(userID string, rules VisibilityRules) error
+	GetVisibilityRules(userID string) (*VisibilityRules, error)
+	GetMultipleVisibilityRules(userIDs []string) (map[string]*VisibilityRules, error)
+	DeleteVisibilityRules(userID string) error
+	SetPrivacySettings(userID string, settings PrivacySettings) error
+	GetPrivacySettings(userID string) (*PrivacySettings, error)
+	GetMultiplePrivacySettings(userIDs []string) (map[string]*PrivacySettings, error)
+	SubscribeExpiredUserStatus(ctx context.Context) (<-chan PresenceExpiry, error)
+	ExpireUserStatus(userID string) (*StatusEvent, error)
+	ExpireDND(userID string) (*StatusEvent, error)
+	PublishStatusEvent(event StatusEvent) error
+	GetStatusHistory(userID string, query StatusHistoryQuery) ([]StatusHistoryEntry, error)
 }
 
 // GetRedisKey returns Redis key for user status
 func GetUserStatusKey(userID string) string {
 	return UserStatusKeyPrefix + userID
 }
+
+// GetUserSessionKey returns Redis key for a single session of a user
+func GetUserSessionKey(userID, sessionID string) string {
+	return UserSessionKeyPrefix + userID + ":" + sessionID
+}
+
+// GetUserSessionsKey returns Redis key for the set of a user's session IDs
+func GetUserSessionsKey(userID string) string {
+	return UserSessionsKeyPrefix + userID
+}
+
+// GetUserLastSeenKey returns Redis key for user's last activity timestamp
+func GetUserLastSeenKey(userID string) string {
+	return UserLastSeenKeyPrefix + userID
+}
+
+// GetCustomStatusKey returns Redis key for user's custom status
+func GetCustomStatusKey(userID string) string {
+	return CustomStatusKeyPrefix + userID
+}
+
+// GetUserDNDKey returns Redis key for user's do-not-disturb period
+func GetUserDNDKey(userID string) string {
+	return UserDNDKeyPrefix + userID
+}
+
+// GetUserStatusVersionKey returns Redis key for the version counter of a user's presence
+func GetUserStatusVersionKey(userID string) string {
+	return UserStatusVersionKeyPrefix + userID
+}
+
+// GetUserPrivacyKey returns Redis key for user's privacy settings
+func GetUserPrivacyKey(userID string) string {
+	return UserPrivacyKeyPrefix + userID
+}
+
+// GetUserContactsKey returns Redis key for the set of user's contact IDs
+func GetUserContactsKey(userID string) string {
+	return UserContactsKeyPrefix + userID
+}
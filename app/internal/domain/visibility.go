@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// Redis key pattern for viewer-specific visibility rules
+const VisibilityRulesKeyPrefix = "user:visibility:"
+
+// Visibility decides what a viewer sees of a user's presence
+type Visibility string
+
+// Visibility constants
+const (
+	VisibilityVisible Visibility = "visible" // Public status as usual
+	VisibilityOffline Visibility = "offline" // Appear offline, without custom status or last activity
+	VisibilityHidden  Visibility = "hidden"  // No presence at all, as if never seen (unknown)
+)
+
+// IsValid reports whether the visibility is a known one
+func (v Visibility) IsValid() bool {
+	switch v {
+	case VisibilityVisible, VisibilityOffline, VisibilityHidden:
+		return true
+	}
+	return false
+}
+
+// VisibilityRule applies a visibility to the listed viewers
+type VisibilityRule struct {
+	Name       string     `json:"name,omitempty"` // e.g. "close friends"
+	Visibility Visibility `json:"visibility"`
+	Viewers    []string   `json:"viewers"`
+}
+
+// VisibilityRules decides per viewer what the user's public presence shows.
+// The first rule listing the viewer wins; every other viewer (anonymous ones
+// included) gets Default. "Appear offline to everyone except close friends" is
+// Default offline with a visible rule for the close friends.
+type VisibilityRules struct {
+	UserID    string           `json:"user_id"`
+	Default   Visibility       `json:"default"`
+	Rules     []VisibilityRule `json:"rules"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// GetVisibilityRulesKey returns Redis key for user's visibility rules
+func GetVisibilityRulesKey(userID string) string {
+	return VisibilityRulesKeyPrefix + userID
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"net/http"
+	"social-app/internal/domain"
+	"social-app/internal/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ContactsHandler struct {
+	service *services.ContactsService
+}
+
+func NewContactsHandler(service *services.ContactsService) *ContactsHandler {
+	return &ContactsHandler{service: service}
+}
+
+// Request DTOs
+type AddContactsRequest struct {
+	UserIDs []string `json:"user_ids" binding:"required"`
+}
+
+// Response DTOs
+type ContactsResponse struct {
+	Success bool     `json:"success"`
+	Data    []string `json:"data,omitempty"`
+	Count   int      `json:"count"`
+	Message string   `json:"message,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type OnlineContactsResponse struct {
+	Success    bool                 `json:"success"`
+	Data       []*domain.UserStatus `json:"data,omitempty"`
+	Count      int                  `json:"count"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+	Error      string               `json:"error,omitempty"`
+}
+
+// POST /users/:id/contacts
+// Add users to the contact list
+func (h *ContactsHandler) AddContacts(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req AddContactsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ContactsResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.service.AddContacts(userID, req.UserIDs); err != nil {
+		c.JSON(errorStatus(err), ContactsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ContactsResponse{
+		Success: true,
+		Count:   len(req.UserIDs),
+		Message: "Contacts added successfully",
+	})
+}
+
+// GET /users/:id/contacts
+// Get the contact list
+func (h *ContactsHandler) GetContacts(c *gin.Context) {
+	userID := c.Param("id")
+
+	contacts, err := h.service.GetContacts(userID)
+	if err != nil {
+		c.JSON(errorStatus(err), ContactsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ContactsResponse{
+		Success: true,
+		Data:    contacts,
+		Count:   len(contacts),
+	})
+}
+
+// DELETE /users/:id/contacts/:contact_id
+// Remove a user from the contact list
+func (h *ContactsHandler) RemoveContact(c *gin.Context) {
+	userID := c.Param("id")
+	contactID := c.Param("contact_id")
+
+	if err := h.service.RemoveContact(userID, contactID); err != nil {
+		c.JSON(errorStatus(err), ContactsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ContactsResponse{
+		Success: true,
+		Message: "Contact removed",
+	})
+}
+
+// GET /users/:id/contacts/online?limit=50&cursor=...
+// Get paginated contacts who are online, away or DND, most recently active first
+func (h *ContactsHandler) GetOnlineContacts(c *gin.Context) {
+	userID := c.Param("id")
+
+	query := domain.OnlineContactsQuery{
+		Cursor: c.Query("cursor"),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		value, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, OnlineContactsResponse{
+				Success: false,
+				Error:   "limit must be a number",
+			})
+			return
+		}
+		query.Limit = value
+	}
+
+	contacts, nextCursor, err := h.service.GetOnlineContacts(userID, query)
+	if err != nil {
+		c.JSON(errorStatus(err), OnlineContactsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, OnlineContactsResponse{
+		Success:    true,
+		Data:       contacts,
+		Count:      len(contacts),
+		NextCursor: nextCursor,
+	})
+}
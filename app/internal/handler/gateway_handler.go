@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+	"social-app/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request DTOs
+type BulkHeartbeatRequest struct {
+	Heartbeats []BulkHeartbeatEntry `json:"heartbeats" binding:"required"`
+}
+
+type BulkHeartbeatEntry struct {
+	UserID      string `json:"user_id"`
+	SessionID   string `json:"session_id"`
+	DeviceType  string `json:"device_type"`
+	IdleSeconds int64  `json:"idle_seconds"`
+	Foreground  *bool  `json:"foreground"`
+	InputActive *bool  `json:"input_active"`
+}
+
+// Response DTOs
+type BulkHeartbeatResponse struct {
+	Success bool                         `json:"success"`
+	Data    []BulkHeartbeatEntryResponse `json:"data,omitempty"`
+	Count   int                          `json:"count"`
+	Failed  int                          `json:"failed"`
+	Error   string                       `json:"error,omitempty"`
+}
+
+type BulkHeartbeatEntryResponse struct {
+	UserID               string        `json:"user_id"`
+	SessionID            string        `json:"session_id,omitempty"`
+	Status               domain.Status `json:"status,omitempty"`
+	NextHeartbeatSeconds int           `json:"next_heartbeat_seconds,omitempty"`
+	Error                string        `json:"error,omitempty"`
+}
+
+// POST /users/heartbeats
+// Send heartbeats for many user sessions at once (internal, e.g. a WebSocket gateway
+// keeping every connection of its node alive). Results are in request order.
+func (h *UserStatusHandler) SendBulkHeartbeat(c *gin.Context) {
+	var req BulkHeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, BulkHeartbeatResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	heartbeats := make([]domain.BulkHeartbeat, len(req.Heartbeats))
+	for i, entry := range req.Heartbeats {
+		heartbeats[i] = domain.BulkHeartbeat{
+			UserID: entry.UserID,
+			Session: domain.ClientSession{
+				SessionID:  entry.SessionID,
+				DeviceType: entry.DeviceType,
+			},
+			Activity: &domain.HeartbeatActivity{
+				IdleSeconds: entry.IdleSeconds,
+				Foreground:  entry.Foreground,
+				InputActive: entry.InputActive,
+			},
+		}
+	}
+
+	results, err := h.service.SendBulkHeartbeat(heartbeats)
+	if err != nil {
+		c.JSON(errorStatus(err), BulkHeartbeatResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	response := BulkHeartbeatResponse{
+		Success: true,
+		Data:    make([]BulkHeartbeatEntryResponse, len(results)),
+		Count:   len(results),
+	}
+	for i, result := range results {
+		response.Data[i] = BulkHeartbeatEntryResponse{
+			UserID:               result.UserID,
+			SessionID:            result.SessionID,
+			Status:               result.Status,
+			NextHeartbeatSeconds: int(result.HeartbeatInterval.Seconds()),
+			Error:                result.Error,
+		}
+		if result.Error != "" {
+			response.Failed++
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"social-app/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response DTOs
+type PresencePolicyResponse struct {
+	Success bool                       `json:"success"`
+	Data    *domain.PresencePolicyView `json:"data,omitempty"`
+	Error   string                     `json:"error,omitempty"`
+}
+
+// GET /presence/policy
+// Get heartbeat interval and status timings for the client's device type
+// (X-Device-Type header or device_type query parameter)
+func (h *UserStatusHandler) GetPresencePolicy(c *gin.Context) {
+	deviceType := c.Query("device_type")
+	if deviceType == "" {
+		deviceType = clientSession(c).DeviceType
+	}
+
+	policy, err := h.service.GetPresencePolicy(deviceType)
+	if err != nil {
+		c.JSON(errorStatus(err), PresencePolicyResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PresencePolicyResponse{
+		Success: true,
+		Data:    policy,
+	})
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"social-app/internal/domain"
+	"social-app/internal/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PresenceStatsHandler struct {
+	service *services.PresenceStatsService
+}
+
+func NewPresenceStatsHandler(service *services.PresenceStatsService) *PresenceStatsHandler {
+	return &PresenceStatsHandler{service: service}
+}
+
+// Response DTOs
+type PresenceStatsResponse struct {
+	Success bool                  `json:"success"`
+	Data    *domain.PresenceStats `json:"data,omitempty"`
+	Error   string                `json:"error,omitempty"`
+}
+
+// GET /admin/presence/stats?date=2026-01-02
+// Get users online now per status and unique daily actives (internal)
+func (h *PresenceStatsHandler) GetPresenceStats(c *gin.Context) {
+	var day time.Time
+	if date := c.Query("date"); date != "" {
+		parsed, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, PresenceStatsResponse{
+				Success: false,
+				Error:   "date must be YYYY-MM-DD",
+			})
+			return
+		}
+		day = parsed
+	}
+
+	stats, err := h.service.GetPresenceStats(day)
+	if err != nil {
+		c.JSON(errorStatus(err), PresenceStatsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PresenceStatsResponse{
+		Success: true,
+		Data:    stats,
+	})
+}
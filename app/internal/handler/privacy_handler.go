@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"social-app/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request DTOs
+type SetPrivacySettingsRequest struct {
+	LastSeen domain.Audience `json:"last_seen" binding:"required"`
+}
+
+// Response DTOs
+type PrivacySettingsResponse struct {
+	Success bool                    `json:"success"`
+	Data    *domain.PrivacySettings `json:"data,omitempty"`
+	Message string                  `json:"message,omitempty"`
+	Error   string                  `json:"error,omitempty"`
+}
+
+// PUT /users/:id/settings/privacy
+// Set who can see the user's last seen (everyone, contacts, nobody)
+func (h *UserStatusHandler) SetPrivacySettings(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req SetPrivacySettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, PrivacySettingsResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	settings, err := h.service.SetPrivacySettings(userID, domain.PrivacySettings{
+		LastSeen: req.LastSeen,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), PrivacySettingsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PrivacySettingsResponse{
+		Success: true,
+		Data:    settings,
+		Message: "Privacy settings updated successfully",
+	})
+}
+
+// GET /users/:id/settings/privacy
+// Get privacy settings (defaults when never set)
+func (h *UserStatusHandler) GetPrivacySettings(c *gin.Context) {
+	userID := c.Param("id")
+
+	settings, err := h.service.GetPrivacySettings(userID)
+	if err != nil {
+		c.JSON(errorStatus(err), PrivacySettingsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PrivacySettingsResponse{
+		Success: true,
+		Data:    settings,
+	})
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+	"social-app/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request DTOs
+type SetQuietHoursRequest struct {
+	TimeZone string                    `json:"time_zone" binding:"required"`
+	Enabled  *bool                     `json:"enabled"`
+	Windows  []domain.QuietHoursWindow `json:"windows" binding:"required"`
+}
+
+// Response DTOs
+type QuietHoursResponse struct {
+	Success bool                       `json:"success"`
+	Data    *domain.QuietHoursSchedule `json:"data,omitempty"`
+	Message string                     `json:"message,omitempty"`
+	Error   string                     `json:"error,omitempty"`
+}
+
+// PUT /users/:id/quiet-hours
+// Create or replace recurring quiet hours schedule
+func (h *UserStatusHandler) SetQuietHours(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req SetQuietHoursRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, QuietHoursResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	schedule, err := h.service.SetQuietHours(userID, domain.QuietHoursSchedule{
+		TimeZone: req.TimeZone,
+		Enabled:  enabled,
+		Windows:  req.Windows,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), QuietHoursResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, QuietHoursResponse{
+		Success: true,
+		Data:    schedule,
+		Message: "Quiet hours updated successfully",
+	})
+}
+
+// GET /users/:id/quiet-hours
+// Get recurring quiet hours schedule
+func (h *UserStatusHandler) GetQuietHours(c *gin.Context) {
+	userID := c.Param("id")
+
+	schedule, err := h.service.GetQuietHours(userID)
+	if err != nil {
+		c.JSON(errorStatus(err), QuietHoursResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if schedule == nil {
+		c.JSON(http.StatusNotFound, QuietHoursResponse{
+			Success: false,
+			Error:   "Quiet hours not configured",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, QuietHoursResponse{
+		Success: true,
+		Data:    schedule,
+	})
+}
+
+// DELETE /users/:id/quiet-hours
+// Remove recurring quiet hours schedule
+func (h *UserStatusHandler) DeleteQuietHours(c *gin.Context) {
+	userID := c.Param("id")
+
+	if err := h.service.DeleteQuietHours(userID); err != nil {
+		c.JSON(errorStatus(err), QuietHoursResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, QuietHoursResponse{
+		Success: true,
+		Message: "Quiet hours removed",
+	})
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"social-app/internal/domain"
+	"social-app/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoomHandler struct {
+	service *services.RoomService
+}
+
+func NewRoomHandler(service *services.RoomService) *RoomHandler {
+	return &RoomHandler{service: service}
+}
+
+// Response DTOs
+type RoomPresenceResponse struct {
+	Success bool                 `json:"success"`
+	Data    *domain.RoomPresence `json:"data,omitempty"`
+	Message string               `json:"message,omitempty"`
+	Error   string               `json:"error,omitempty"`
+}
+
+// PUT /rooms/:room_id/members/:user_id
+// Join a room (the user stays present while heartbeats keep them online or away)
+func (h *RoomHandler) JoinRoom(c *gin.Context) {
+	roomID := c.Param("room_id")
+	userID := c.Param("user_id")
+
+	if err := h.service.JoinRoom(roomID, userID); err != nil {
+		c.JSON(errorStatus(err), RoomPresenceResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, RoomPresenceResponse{
+		Success: true,
+		Message: "Joined room " + roomID,
+	})
+}
+
+// DELETE /rooms/:room_id/members/:user_id
+// Leave a room
+func (h *RoomHandler) LeaveRoom(c *gin.Context) {
+	roomID := c.Param("room_id")
+	userID := c.Param("user_id")
+
+	if err := h.service.LeaveRoom(roomID, userID); err != nil {
+		c.JSON(errorStatus(err), RoomPresenceResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, RoomPresenceResponse{
+		Success: true,
+		Message: "Left room " + roomID,
+	})
+}
+
+// GET /rooms/:room_id/members
+// Get members currently present in a room with their statuses, as visible to the viewer in X-Viewer-ID
+func (h *RoomHandler) GetRoomPresence(c *gin.Context) {
+	roomID := c.Param("room_id")
+
+	presence, err := h.service.GetRoomPresence(roomID, c.GetHeader(ViewerIDHeader))
+	if err != nil {
+		c.JSON(errorStatus(err), RoomPresenceResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, RoomPresenceResponse{
+		Success: true,
+		Data:    presence,
+	})
+}
+
+// GET /rooms/:room_id/count
+// Get the number of members currently present in a room, per status
+func (h *RoomHandler) GetRoomCount(c *gin.Context) {
+	roomID := c.Param("room_id")
+
+	presence, err := h.service.GetRoomCount(roomID, c.GetHeader(ViewerIDHeader))
+	if err != nil {
+		c.JSON(errorStatus(err), RoomPresenceResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, RoomPresenceResponse{
+		Success: true,
+		Data:    presence,
+	})
+}
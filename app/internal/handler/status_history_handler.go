@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"social-app/internal/domain"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response DTOs
+type StatusHistoryResponse struct {
+	Success    bool                        `json:"success"`
+	Data       []domain.StatusHistoryEntry `json:"data,omitempty"`
+	Count      int                         `json:"count,omitempty"`
+	NextCursor string                      `json:"next_cursor,omitempty"`
+	Error      string                      `json:"error,omitempty"`
+}
+
+// GET /users/:id/status/history?limit=50&cursor=...&from=...&to=...
+// Get paginated status change history (newest first)
+func (h *UserStatusHandler) GetStatusHistory(c *gin.Context) {
+	userID := c.Param("id")
+
+	query := domain.StatusHistoryQuery{
+		Cursor: c.Query("cursor"),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		value, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, StatusHistoryResponse{
+				Success: false,
+				Error:   "limit must be a number",
+			})
+			return
+		}
+		query.Limit = value
+	}
+
+	var err error
+	if query.From, err = parseTimeQuery(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, StatusHistoryResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if query.To, err = parseTimeQuery(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, StatusHistoryResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	entries, nextCursor, err := h.service.GetStatusHistory(userID, query)
+	if err != nil {
+		c.JSON(errorStatus(err), StatusHistoryResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, StatusHistoryResponse{
+		Success:    true,
+		Data:       entries,
+		Count:      len(entries),
+		NextCursor: nextCursor,
+	})
+}
+
+// parseTimeQuery parses an optional RFC3339 query parameter
+func parseTimeQuery(c *gin.Context, param string) (*time.Time, error) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New(param + " must be an RFC3339 timestamp")
+	}
+	return &t, nil
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"social-app/internal/domain"
+	"social-app/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TypingHandler struct {
+	service *services.TypingService
+}
+
+func NewTypingHandler(service *services.TypingService) *TypingHandler {
+	return &TypingHandler{service: service}
+}
+
+// Response DTOs
+type TypingResponse struct {
+	Success          bool                `json:"success"`
+	Data             *domain.TypingState `json:"data,omitempty"`
+	ExpiresInSeconds int                 `json:"expires_in_seconds,omitempty"`
+	Message          string              `json:"message,omitempty"`
+	Error            string              `json:"error,omitempty"`
+}
+
+// PUT /conversations/:conversation_id/typing/:user_id
+// Start typing (repeat while typing; the indicator expires after a few seconds)
+func (h *TypingHandler) StartTyping(c *gin.Context) {
+	conversationID := c.Param("conversation_id")
+	userID := c.Param("user_id")
+
+	shown, err := h.service.StartTyping(conversationID, userID)
+	if err != nil {
+		c.JSON(errorStatus(err), TypingResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if !shown {
+		c.JSON(http.StatusOK, TypingResponse{
+			Success: true,
+			Message: "Typing indicator suppressed while invisible",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, TypingResponse{
+		Success:          true,
+		ExpiresInSeconds: int(domain.TypingTTL.Seconds()),
+		Message:          "Typing indicator sent",
+	})
+}
+
+// DELETE /conversations/:conversation_id/typing/:user_id
+// Stop typing
+func (h *TypingHandler) StopTyping(c *gin.Context) {
+	conversationID := c.Param("conversation_id")
+	userID := c.Param("user_id")
+
+	if err := h.service.StopTyping(conversationID, userID); err != nil {
+		c.JSON(errorStatus(err), TypingResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, TypingResponse{
+		Success: true,
+		Message: "Typing indicator cleared",
+	})
+}
+
+// GET /conversations/:conversation_id/typing
+// Get who is typing, as visible to the viewer in X-Viewer-ID
+func (h *TypingHandler) GetTypingUsers(c *gin.Context) {
+	conversationID := c.Param("conversation_id")
+
+	state, err := h.service.GetTypingUsers(conversationID, c.GetHeader(ViewerIDHeader))
+	if err != nil {
+		c.JSON(errorStatus(err), TypingResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, TypingResponse{
+		Success: true,
+		Data:    state,
+	})
+}
@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"social-app/internal/domain"
 	"social-app/internal/services"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,8 +19,25 @@ type SetStatusRequest struct {
 	Status string `json:"status" binding:"required"`
 }
 
+type SetCustomStatusRequest struct {
+	Text              string `json:"text"`
+	Emoji             string `json:"emoji"`
+	ClearAfterSeconds int64  `json:"clear_after_seconds"`
+}
+
+type SetDNDRequest struct {
+	DurationSeconds int64      `json:"duration_seconds"`
+	Until           *time.Time `json:"until"`
+}
+
+type BulkStatusRequest struct {
+	UserIDs []string `json:"user_ids" binding:"required"`
+}
+
 type HeartbeatRequest struct {
-	UserID string `json:"user_id" binding:"required"`
+	IdleSeconds int64 `json:"idle_seconds"`
+	Foreground  *bool `json:"foreground"`
+	InputActive *bool `json:"input_active"`
 }
 
 // Response DTOs
@@ -31,16 +52,41 @@ type MultipleUserStatusResponse struct {
 	Success bool                          `json:"success"`
 	Data    map[string]*domain.UserStatus `json:"data,omitempty"`
 	Count   int                           `json:"count,omitempty"`
+	Errors  map[string]string             `json:"errors,omitempty"` // Rejected user IDs and why
 	Error   string                        `json:"error,omitempty"`
 }
 
+type CustomStatusResponse struct {
+	Success bool                 `json:"success"`
+	Data    *domain.CustomStatus `json:"data,omitempty"`
+	Message string               `json:"message,omitempty"`
+	Error   string               `json:"error,omitempty"`
+}
+
+type LastSeenResponse struct {
+	Success bool             `json:"success"`
+	Data    *domain.LastSeen `json:"data,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
 type HeartbeatResponse struct {
-	Success              bool   `json:"success"`
-	Message              string `json:"message,omitempty"`
-	NextHeartbeatSeconds int    `json:"next_heartbeat_seconds,omitempty"`
-	Error                string `json:"error,omitempty"`
+	Success              bool          `json:"success"`
+	Status               domain.Status `json:"status,omitempty"`
+	Message              string        `json:"message,omitempty"`
+	NextHeartbeatSeconds int           `json:"next_heartbeat_seconds,omitempty"`
+	Error                string        `json:"error,omitempty"`
 }
 
+// Headers identifying the client session a request comes from
+const (
+	SessionIDHeader  = "X-Session-ID"
+	DeviceTypeHeader = "X-Device-Type"
+)
+
+// ViewerIDHeader identifies the user looking at someone's presence, so their
+// visibility rules can be applied. Requests without it are anonymous viewers.
+const ViewerIDHeader = "X-Viewer-ID"
+
 type UserStatusHandler struct {
 	service *services.UserStatusService
 }
@@ -64,15 +110,17 @@ func (h *UserStatusHandler) SetUserStatus(c *gin.Context) {
 	}
 
 	// Validate status value
-	if !isValidStatus(req.Status) {
+	status, err := domain.ParseStatus(req.Status)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, UserStatusResponse{
 			Success: false,
-			Error:   "Invalid status. Must be one of: online, away, offline, invisible, dnd",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	if err := h.service.SetUserStatus(userID, req.Status); err != nil {
+	version, err := parseIfMatch(c.GetHeader("If-Match"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, UserStatusResponse{
 			Success: false,
 			Error:   err.Error(),
@@ -80,12 +128,23 @@ func (h *UserStatusHandler) SetUserStatus(c *gin.Context) {
 		return
 	}
 
+	newVersion, err := h.service.SetUserStatusIfVersion(userID, clientSession(c), status, version)
+	if err != nil {
+		c.JSON(errorStatus(err), UserStatusResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.Header("ETag", formatETag(newVersion))
 	c.JSON(http.StatusOK, UserStatusResponse{
 		Success: true,
 		Data: &domain.UserStatus{
 			UserID:    userID,
-			Status:    req.Status,
+			Status:    status,
 			Timestamp: time.Now(),
+			Version:   newVersion,
 		},
 		Message: "User status updated successfully",
 	})
@@ -98,13 +157,14 @@ func (h *UserStatusHandler) GetUserStatus(c *gin.Context) {
 
 	status, err := h.service.GetUserStatus(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, UserStatusResponse{
+		c.JSON(errorStatus(err), UserStatusResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
 		return
 	}
 
+	c.Header("ETag", formatETag(status.Version))
 	c.JSON(http.StatusOK, UserStatusResponse{
 		Success: true,
 		Data:    status,
@@ -112,10 +172,37 @@ func (h *UserStatusHandler) GetUserStatus(c *gin.Context) {
 }
 
 // GET /users/status?user_ids=123,456,789
-// Get multiple users status
+// Get multiple users raw status (internal callers only)
 func (h *UserStatusHandler) GetMultipleUserStatus(c *gin.Context) {
-	userIDs := c.QueryArray("user_ids")
+	userIDs := parseUserIDs(c)
+	if len(userIDs) == 0 {
+		c.JSON(http.StatusBadRequest, MultipleUserStatusResponse{
+			Success: false,
+			Error:   "user_ids parameter is required",
+		})
+		return
+	}
+
+	result, err := h.service.GetMultipleUserStatus(userIDs)
+	respondBulkStatus(c, result, err)
+}
+
+// POST /users/status
+// Get multiple users raw status for ID lists too long for a query string (internal callers only)
+func (h *UserStatusHandler) QueryMultipleUserStatus(c *gin.Context) {
+	userIDs, ok := bindBulkStatusRequest(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetMultipleUserStatus(userIDs)
+	respondBulkStatus(c, result, err)
+}
 
+// GET /users/status/public?user_ids=123,456,789
+// Get multiple users status as visible to the viewer in X-Viewer-ID (friend lists)
+func (h *UserStatusHandler) GetMultiplePublicUserStatus(c *gin.Context) {
+	userIDs := parseUserIDs(c)
 	if len(userIDs) == 0 {
 		c.JSON(http.StatusBadRequest, MultipleUserStatusResponse{
 			Success: false,
@@ -124,9 +211,62 @@ func (h *UserStatusHandler) GetMultipleUserStatus(c *gin.Context) {
 		return
 	}
 
-	statuses, err := h.service.GetMultipleUserStatus(userIDs)
+	result, err := h.service.GetMultiplePublicUserStatus(userIDs, c.GetHeader(ViewerIDHeader))
+	respondBulkStatus(c, result, err)
+}
+
+// POST /users/status/public
+// Get multiple users status as visible to the viewer in X-Viewer-ID, for ID lists
+// too long for a query string (e.g. large friend lists)
+func (h *UserStatusHandler) QueryMultiplePublicUserStatus(c *gin.Context) {
+	userIDs, ok := bindBulkStatusRequest(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.GetMultiplePublicUserStatus(userIDs, c.GetHeader(ViewerIDHeader))
+	respondBulkStatus(c, result, err)
+}
+
+// parseUserIDs reads user_ids from the query string, accepting both a comma
+// separated list (user_ids=1,2) and repeated parameters (user_ids=1&user_ids=2)
+func parseUserIDs(c *gin.Context) []string {
+	var userIDs []string
+	for _, value := range c.QueryArray("user_ids") {
+		for _, userID := range strings.Split(value, ",") {
+			if userID = strings.TrimSpace(userID); userID != "" {
+				userIDs = append(userIDs, userID)
+			}
+		}
+	}
+	return userIDs
+}
+
+// bindBulkStatusRequest reads the user IDs of a POST bulk status request,
+// writing the error response itself when the body is invalid
+func bindBulkStatusRequest(c *gin.Context) ([]string, bool) {
+	var req BulkStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, MultipleUserStatusResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return nil, false
+	}
+	if len(req.UserIDs) == 0 {
+		c.JSON(http.StatusBadRequest, MultipleUserStatusResponse{
+			Success: false,
+			Error:   "user_ids cannot be empty",
+		})
+		return nil, false
+	}
+	return req.UserIDs, true
+}
+
+// respondBulkStatus writes the response of a bulk status lookup
+func respondBulkStatus(c *gin.Context, result *domain.BulkStatusResult, err error) {
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, MultipleUserStatusResponse{
+		c.JSON(errorStatus(err), MultipleUserStatusResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -135,18 +275,39 @@ func (h *UserStatusHandler) GetMultipleUserStatus(c *gin.Context) {
 
 	c.JSON(http.StatusOK, MultipleUserStatusResponse{
 		Success: true,
-		Data:    statuses,
-		Count:   len(statuses),
+		Data:    result.Statuses,
+		Count:   len(result.Statuses),
+		Errors:  result.Errors,
 	})
 }
 
 // POST /users/:id/heartbeat
-// Send heartbeat to maintain online status
+// Send heartbeat to maintain online status of the session in X-Session-ID.
+// Optional body reports client activity (idle time, foreground, input).
 func (h *UserStatusHandler) SendHeartbeat(c *gin.Context) {
 	userID := c.Param("id")
 
-	if err := h.service.SendHeartbeat(userID); err != nil {
+	var activity *domain.HeartbeatActivity
+	var req HeartbeatRequest
+	hasBody, err := bindOptionalJSON(c, &req)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, HeartbeatResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	if hasBody {
+		activity = &domain.HeartbeatActivity{
+			IdleSeconds: req.IdleSeconds,
+			Foreground:  req.Foreground,
+			InputActive: req.InputActive,
+		}
+	}
+
+	result, err := h.service.SendHeartbeat(userID, clientSession(c), activity)
+	if err != nil {
+		c.JSON(errorStatus(err), HeartbeatResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -155,8 +316,9 @@ func (h *UserStatusHandler) SendHeartbeat(c *gin.Context) {
 
 	c.JSON(http.StatusOK, HeartbeatResponse{
 		Success:              true,
+		Status:               result.Status,
 		Message:              "Heartbeat received successfully",
-		NextHeartbeatSeconds: 30, // Online TTL duration
+		NextHeartbeatSeconds: int(result.HeartbeatInterval.Seconds()),
 	})
 }
 
@@ -165,8 +327,8 @@ func (h *UserStatusHandler) SendHeartbeat(c *gin.Context) {
 func (h *UserStatusHandler) SetUserAway(c *gin.Context) {
 	userID := c.Param("id")
 
-	if err := h.service.SetUserAway(userID); err != nil {
-		c.JSON(http.StatusBadRequest, UserStatusResponse{
+	if err := h.service.SetUserAway(userID, clientSession(c)); err != nil {
+		c.JSON(errorStatus(err), UserStatusResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -189,8 +351,8 @@ func (h *UserStatusHandler) SetUserAway(c *gin.Context) {
 func (h *UserStatusHandler) SetUserOffline(c *gin.Context) {
 	userID := c.Param("id")
 
-	if err := h.service.SetUserOffline(userID); err != nil {
-		c.JSON(http.StatusBadRequest, UserStatusResponse{
+	if err := h.service.SetUserOffline(userID, clientSession(c)); err != nil {
+		c.JSON(errorStatus(err), UserStatusResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -213,8 +375,8 @@ func (h *UserStatusHandler) SetUserOffline(c *gin.Context) {
 func (h *UserStatusHandler) SetUserInvisible(c *gin.Context) {
 	userID := c.Param("id")
 
-	if err := h.service.SetUserInvisible(userID); err != nil {
-		c.JSON(http.StatusBadRequest, UserStatusResponse{
+	if err := h.service.SetUserInvisible(userID, clientSession(c)); err != nil {
+		c.JSON(errorStatus(err), UserStatusResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -238,8 +400,40 @@ func (h *UserStatusHandler) SetUserDND(c *gin.Context) {
 	userID := c.Param("id")
 	fmt.Printf("🔍 [DEBUG] SetUserDND - Received userID: '%s'\n", userID)
 
-	if err := h.service.SetUserDND(userID); err != nil {
+	// Body is optional: without it DND lasts until cleared
+	var req SetDNDRequest
+	if _, err := bindOptionalJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, UserStatusResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if req.DurationSeconds < 0 {
 		c.JSON(http.StatusBadRequest, UserStatusResponse{
+			Success: false,
+			Error:   "duration_seconds cannot be negative",
+		})
+		return
+	}
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, UserStatusResponse{
+			Success: false,
+			Error:   "until must be in the future",
+		})
+		return
+	}
+
+	until := req.Until
+	if until == nil && req.DurationSeconds > 0 {
+		deadline := time.Now().Add(time.Duration(req.DurationSeconds) * time.Second)
+		until = &deadline
+	}
+
+	dnd, err := h.service.SetUserDND(userID, until)
+	if err != nil {
+		c.JSON(errorStatus(err), UserStatusResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -252,19 +446,39 @@ func (h *UserStatusHandler) SetUserDND(c *gin.Context) {
 			UserID:    userID,
 			Status:    domain.StatusDND,
 			Timestamp: time.Now(),
+			DNDUntil:  dnd.Until,
 		},
 		Message: "User set to do not disturb status",
 	})
 }
 
+// DELETE /users/:id/status/dnd
+// End do not disturb before its scheduled end
+func (h *UserStatusHandler) ClearUserDND(c *gin.Context) {
+	userID := c.Param("id")
+
+	if err := h.service.ClearUserDND(userID); err != nil {
+		c.JSON(errorStatus(err), UserStatusResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, UserStatusResponse{
+		Success: true,
+		Message: "Do not disturb ended",
+	})
+}
+
 // GET /users/:id/status/public
-// Get user status visible to other users (handles invisible mode)
+// Get user status as visible to the viewer in X-Viewer-ID (handles invisible mode and visibility rules)
 func (h *UserStatusHandler) GetPublicUserStatus(c *gin.Context) {
 	userID := c.Param("id")
 
-	status, err := h.service.GetPublicUserStatus(userID)
+	status, err := h.service.GetPublicUserStatus(userID, c.GetHeader(ViewerIDHeader))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, UserStatusResponse{
+		c.JSON(errorStatus(err), UserStatusResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -277,12 +491,157 @@ func (h *UserStatusHandler) GetPublicUserStatus(c *gin.Context) {
 	})
 }
 
-// Helper function to validate status
-func isValidStatus(status string) bool {
-	switch status {
-	case domain.StatusOnline, domain.StatusAway, domain.StatusOffline, domain.StatusInvisible, domain.StatusDND:
-		return true
+// PUT /users/:id/status/custom
+// Set custom status text/emoji with optional auto-clear
+func (h *UserStatusHandler) SetCustomStatus(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req SetCustomStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, CustomStatusResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	custom, err := h.service.SetCustomStatus(userID, domain.CustomStatus{
+		Text:  req.Text,
+		Emoji: req.Emoji,
+	}, time.Duration(req.ClearAfterSeconds)*time.Second)
+	if err != nil {
+		c.JSON(errorStatus(err), CustomStatusResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, CustomStatusResponse{
+		Success: true,
+		Data:    custom,
+		Message: "Custom status updated successfully",
+	})
+}
+
+// DELETE /users/:id/status/custom
+// Clear custom status
+func (h *UserStatusHandler) ClearCustomStatus(c *gin.Context) {
+	userID := c.Param("id")
+
+	if err := h.service.ClearCustomStatus(userID); err != nil {
+		c.JSON(errorStatus(err), CustomStatusResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, CustomStatusResponse{
+		Success: true,
+		Message: "Custom status cleared",
+	})
+}
+
+// DELETE /users/:id/sessions/:session_id
+// Log a client session out; a manually chosen status on it is dropped
+func (h *UserStatusHandler) EndSession(c *gin.Context) {
+	userID := c.Param("id")
+
+	if err := h.service.EndSession(userID, c.Param("session_id")); err != nil {
+		c.JSON(errorStatus(err), UserStatusResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, UserStatusResponse{
+		Success: true,
+		Message: "Session ended",
+	})
+}
+
+// GET /users/:id/last-seen
+// Get when user was last active ("last seen X ago") as visible to the viewer in X-Viewer-ID
+func (h *UserStatusHandler) GetLastSeen(c *gin.Context) {
+	userID := c.Param("id")
+
+	lastSeen, err := h.service.GetLastSeen(userID, c.GetHeader(ViewerIDHeader))
+	if err != nil {
+		c.JSON(errorStatus(err), LastSeenResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, LastSeenResponse{
+		Success: true,
+		Data:    lastSeen,
+	})
+}
+
+// bindOptionalJSON binds the JSON body if the request has one and reports whether it did.
+// Chunked and HTTP/2 requests may not announce their length (ContentLength -1), so the
+// body is read whenever one may be present, and an empty one counts as no body.
+func bindOptionalJSON(c *gin.Context, obj any) (bool, error) {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody || c.Request.ContentLength == 0 {
+		return false, nil
+	}
+	if err := c.ShouldBindJSON(obj); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// errorStatus maps a service error to its HTTP status: a version conflict is 412,
+// errors caused by the request are 400 and everything else (Redis failures) is 500
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, domain.ErrInvalidInput),
+		errors.Is(err, domain.ErrTooManyUserIDs),
+		errors.Is(err, domain.ErrRoomFull),
+		errors.Is(err, domain.ErrUserNotPresent):
+		return http.StatusBadRequest
 	default:
-		return false
+		return http.StatusInternalServerError
+	}
+}
+
+// clientSession extracts the client session from request headers
+func clientSession(c *gin.Context) domain.ClientSession {
+	return domain.ClientSession{
+		SessionID:  c.GetHeader(SessionIDHeader),
+		DeviceType: c.GetHeader(DeviceTypeHeader),
+	}
+}
+
+// formatETag renders a presence version as a strong entity tag
+func formatETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// parseIfMatch parses an If-Match header holding a single presence ETag.
+// A missing header or "*" means the write is unconditional.
+func parseIfMatch(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return domain.AnyVersion, nil
+	}
+
+	tag, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, errors.New("invalid If-Match header: expected a single quoted ETag")
+	}
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version < 0 {
+		return 0, errors.New("invalid If-Match header: unknown ETag")
 	}
+	return version, nil
 }
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+	"social-app/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request DTOs
+type SetVisibilityRulesRequest struct {
+	Default domain.Visibility       `json:"default"`
+	Rules   []domain.VisibilityRule `json:"rules"`
+}
+
+// Response DTOs
+type VisibilityRulesResponse struct {
+	Success bool                    `json:"success"`
+	Data    *domain.VisibilityRules `json:"data,omitempty"`
+	Message string                  `json:"message,omitempty"`
+	Error   string                  `json:"error,omitempty"`
+}
+
+// PUT /users/:id/visibility
+// Create or replace viewer-specific visibility rules
+func (h *UserStatusHandler) SetVisibilityRules(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req SetVisibilityRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, VisibilityRulesResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	rules, err := h.service.SetVisibilityRules(userID, domain.VisibilityRules{
+		Default: req.Default,
+		Rules:   req.Rules,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), VisibilityRulesResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, VisibilityRulesResponse{
+		Success: true,
+		Data:    rules,
+		Message: "Visibility rules updated successfully",
+	})
+}
+
+// GET /users/:id/visibility
+// Get viewer-specific visibility rules
+func (h *UserStatusHandler) GetVisibilityRules(c *gin.Context) {
+	userID := c.Param("id")
+
+	rules, err := h.service.GetVisibilityRules(userID)
+	if err != nil {
+		c.JSON(errorStatus(err), VisibilityRulesResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if rules == nil {
+		c.JSON(http.StatusNotFound, VisibilityRulesResponse{
+			Success: false,
+			Error:   "Visibility rules not configured",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, VisibilityRulesResponse{
+		Success: true,
+		Data:    rules,
+	})
+}
+
+// DELETE /users/:id/visibility
+// Remove visibility rules (presence is visible to everyone again)
+func (h *UserStatusHandler) DeleteVisibilityRules(c *gin.Context) {
+	userID := c.Param("id")
+
+	if err := h.service.DeleteVisibilityRules(userID); err != nil {
+		c.JSON(errorStatus(err), VisibilityRulesResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, VisibilityRulesResponse{
+		Success: true,
+		Message: "Visibility rules removed",
+	})
+}
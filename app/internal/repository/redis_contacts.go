@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"social-app/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisContactsRepository stores contact lists in Redis
+type RedisContactsRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+// NewRedisContactsRepository creates a new RedisContactsRepository with the given Redis client
+func NewRedisContactsRepository(client *redis.Client) domain.ContactsRepository {
+	return &RedisContactsRepository{
+		client: client,
+		ctx:    context.Background(),
+	}
+}
+
+// AddContacts adds users to user's contact list (no expiry)
+func (r *RedisContactsRepository) AddContacts(userID string, contactIDs []string) error {
+	if len(contactIDs) == 0 {
+		return nil
+	}
+	return r.client.SAdd(r.ctx, domain.GetUserContactsKey(userID), toInterfaces(contactIDs)...).Err()
+}
+
+// RemoveContact removes a user from user's contact list
+func (r *RedisContactsRepository) RemoveContact(userID, contactID string) error {
+	return r.client.SRem(r.ctx, domain.GetUserContactsKey(userID), contactID).Err()
+}
+
+// GetContacts gets user's contact list
+func (r *RedisContactsRepository) GetContacts(userID string) ([]string, error) {
+	return r.client.SMembers(r.ctx, domain.GetUserContactsKey(userID)).Result()
+}
+
+// HasContacts reports for each of contactIDs whether it is in user's contact list using SMISMEMBER
+func (r *RedisContactsRepository) HasContacts(userID string, contactIDs []string) (map[string]bool, error) {
+	found := make(map[string]bool, len(contactIDs))
+	if len(contactIDs) == 0 {
+		return found, nil
+	}
+
+	values, err := r.client.SMIsMember(r.ctx, domain.GetUserContactsKey(userID), toInterfaces(contactIDs)...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, contactID := range contactIDs {
+		found[contactID] = values[i]
+	}
+	return found, nil
+}
+
+// GetMultipleHasContact reports for each of userIDs whether contactID is in
+// that user's contact list, in a single pipeline
+func (r *RedisContactsRepository) GetMultipleHasContact(userIDs []string, contactID string) (map[string]bool, error) {
+	found := make(map[string]bool, len(userIDs))
+	if len(userIDs) == 0 {
+		return found, nil
+	}
+
+	cmds := make([]*redis.BoolCmd, len(userIDs))
+	_, err := r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for i, userID := range userIDs {
+			cmds[i] = pipe.SIsMember(r.ctx, domain.GetUserContactsKey(userID), contactID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		found[userID] = cmds[i].Val()
+	}
+	return found, nil
+}
+
+// activeContactsChunk caps the members of a single ZMSCORE
+const activeContactsChunk = 1000
+
+// GetActiveContacts gets user's contacts that sent a heartbeat since the given time.
+// Contacts are looked up in the heartbeat recency set of their shard, with one
+// pipeline of chunked ZMSCORE calls. Old entries are trimmed by the heartbeat path,
+// so the read writes nothing.
+func (r *RedisContactsRepository) GetActiveContacts(userID string, since time.Time) ([]domain.ContactActivity, error) {
+	contacts, err := r.client.SMembers(r.ctx, domain.GetUserContactsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(contacts) == 0 {
+		return []domain.ContactActivity{}, nil
+	}
+
+	shards := make(map[int][]string)
+	for _, contactID := range contacts {
+		shard := presenceStatsShard(contactID)
+		shards[shard] = append(shards[shard], contactID)
+	}
+
+	type lookup struct {
+		userIDs []string
+		cmd     *redis.FloatSliceCmd
+	}
+	var lookups []lookup
+	_, err = r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for shard, userIDs := range shards {
+			key := domain.GetPresenceHeartbeatsKey(shard)
+			for start := 0; start < len(userIDs); start += activeContactsChunk {
+				chunk := userIDs[start:min(start+activeContactsChunk, len(userIDs))]
+				lookups = append(lookups, lookup{userIDs: chunk, cmd: pipe.ZMScore(r.ctx, key, chunk...)})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// ZMSCORE reports members without a score as 0
+	sinceMs := float64(since.UnixMilli())
+	active := make([]domain.ContactActivity, 0)
+	for _, lookup := range lookups {
+		for i, ms := range lookup.cmd.Val() {
+			if ms > 0 && ms >= sinceMs {
+				active = append(active, domain.ContactActivity{
+					UserID:        lookup.userIDs[i],
+					LastHeartbeat: time.UnixMilli(int64(ms)),
+				})
+			}
+		}
+	}
+	return active, nil
+}
+
+// SaveContactsSnapshot stores the candidates of a paginated online contacts listing
+// for domain.ContactsSnapshotTTL, so later pages keep the heartbeat order of the first
+func (r *RedisContactsRepository) SaveContactsSnapshot(userID, snapshot string, contacts []domain.ContactActivity) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+
+	members := make([]redis.Z, len(contacts))
+	for i, contact := range contacts {
+		members[i] = redis.Z{Score: float64(contact.LastHeartbeat.UnixMilli()), Member: contact.UserID}
+	}
+
+	key := domain.GetContactsSnapshotKey(userID, snapshot)
+	_, err := r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(r.ctx, key)
+		pipe.ZAdd(r.ctx, key, members...)
+		pipe.PExpire(r.ctx, key, domain.ContactsSnapshotTTL)
+		return nil
+	})
+	return err
+}
+
+// GetContactsSnapshot gets the candidates stored by SaveContactsSnapshot (none once it expired)
+func (r *RedisContactsRepository) GetContactsSnapshot(userID, snapshot string) ([]domain.ContactActivity, error) {
+	members, err := r.client.ZRangeWithScores(r.ctx, domain.GetContactsSnapshotKey(userID, snapshot), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	contacts := make([]domain.ContactActivity, len(members))
+	for i, member := range members {
+		contacts[i] = domain.ContactActivity{
+			UserID:        member.Member.(string),
+			LastHeartbeat: time.UnixMilli(int64(member.Score)),
+		}
+	}
+	return contacts, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"encoding/json"
+	"time"
+
+	"social-app/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SetCustomStatus stores custom status text/emoji. A zero TTL keeps it until cleared.
+func (r *RedisUserStatusRepository) SetCustomStatus(userID string, custom domain.CustomStatus, ttl time.Duration) error {
+	data, err := json.Marshal(custom)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, domain.GetCustomStatusKey(userID), data, ttl).Err()
+}
+
+// GetCustomStatus gets custom status, returning nil if none is set or it has expired
+func (r *RedisUserStatusRepository) GetCustomStatus(userID string) (*domain.CustomStatus, error) {
+	raw, err := r.client.Get(r.ctx, domain.GetCustomStatusKey(userID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var custom domain.CustomStatus
+	if err := json.Unmarshal([]byte(raw), &custom); err != nil {
+		return nil, nil
+	}
+	return &custom, nil
+}
+
+// GetMultipleCustomStatus gets custom statuses of multiple users using MGET
+func (r *RedisUserStatusRepository) GetMultipleCustomStatus(userIDs []string) (map[string]*domain.CustomStatus, error) {
+	customs := make(map[string]*domain.CustomStatus)
+	if len(userIDs) == 0 {
+		return customs, nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = domain.GetCustomStatusKey(userID)
+	}
+
+	values, err := r.mget(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		raw, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		var custom domain.CustomStatus
+		if err := json.Unmarshal([]byte(raw), &custom); err != nil {
+			continue
+		}
+		customs[userID] = &custom
+	}
+
+	return customs, nil
+}
+
+// ClearCustomStatus removes custom status
+func (r *RedisUserStatusRepository) ClearCustomStatus(userID string) error {
+	return r.client.Del(r.ctx, domain.GetCustomStatusKey(userID)).Err()
+}
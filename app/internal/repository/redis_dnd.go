@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"social-app/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SetDND stores a do-not-disturb period. The key expires exactly at the deadline,
+// or never if the period has no end.
+func (r *RedisUserStatusRepository) SetDND(userID string, dnd domain.DNDState) error {
+	_, err := r.CompareAndSetDND(userID, dnd, domain.AnyVersion)
+	return err
+}
+
+// CompareAndSetDND stores a do-not-disturb period only if the user's presence version
+// still equals version (domain.AnyVersion skips the check). Returns the new version,
+// or domain.ErrVersionConflict if the presence changed meanwhile.
+// The DND key expires exactly at the deadline; the change is recorded in status history.
+func (r *RedisUserStatusRepository) CompareAndSetDND(userID string, dnd domain.DNDState, version int64) (int64, error) {
+	data, err := json.Marshal(dnd)
+	if err != nil {
+		return 0, err
+	}
+
+	var ttl time.Duration
+	if dnd.Until != nil {
+		ttl = time.Until(*dnd.Until)
+		if ttl <= 0 {
+			return 0, errors.New("DND end time must be in the future")
+		}
+	}
+
+	expected := ""
+	if version != domain.AnyVersion {
+		expected = strconv.FormatInt(version, 10)
+	}
+
+	values, err := r.runStatusScript(r.scripts.setDND, userID, "", ttl, string(data), expected).StringSlice()
+	if err != nil {
+		return 0, err
+	}
+	if len(values) != 2 {
+		return 0, fmt.Errorf("unexpected set DND reply: %v", values)
+	}
+	if values[0] != "ok" {
+		return 0, domain.ErrVersionConflict
+	}
+	return strconv.ParseInt(values[1], 10, 64)
+}
+
+// GetDND gets the do-not-disturb period, returning nil if none is active
+func (r *RedisUserStatusRepository) GetDND(userID string) (*domain.DNDState, error) {
+	raw, err := r.client.Get(r.ctx, domain.GetUserDNDKey(userID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDND(raw), nil
+}
+
+// GetMultipleDND gets do-not-disturb periods of multiple users using MGET
+func (r *RedisUserStatusRepository) GetMultipleDND(userIDs []string) (map[string]*domain.DNDState, error) {
+	states := make(map[string]*domain.DNDState)
+	if len(userIDs) == 0 {
+		return states, nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = domain.GetUserDNDKey(userID)
+	}
+
+	values, err := r.mget(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		raw, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		if dnd := parseDND(raw); dnd != nil {
+			states[userID] = dnd
+		}
+	}
+
+	return states, nil
+}
+
+// ClearDND ends the do-not-disturb period early
+func (r *RedisUserStatusRepository) ClearDND(userID string) error {
+	_, err := r.endDND(userID, domain.CauseDNDCleared)
+	return err
+}
+
+// ExpireDND records the end of a do-not-disturb period whose key expired at its deadline.
+// Returns the change of the shown status, or nil if there was none (or it was already recorded).
+func (r *RedisUserStatusRepository) ExpireDND(userID string) (*domain.StatusEvent, error) {
+	return r.endDND(userID, domain.CauseDNDEnded)
+}
+
+// endDND ends do-not-disturb with the given cause and returns the resulting status change, if any
+func (r *RedisUserStatusRepository) endDND(userID, cause string) (*domain.StatusEvent, error) {
+	values, err := r.runStatusScript(r.scripts.endDND, userID, "", 0, cause, "").StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 2 {
+		return nil, fmt.Errorf("unexpected end DND reply: %v", values)
+	}
+	if values[0] == values[1] {
+		return nil, nil
+	}
+
+	return &domain.StatusEvent{
+		UserID:    userID,
+		OldStatus: domain.Status(values[0]),
+		NewStatus: domain.Status(values[1]),
+		Cause:     cause,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// parseDND decodes a stored DND period, ignoring it if the deadline already passed
+func parseDND(raw string) *domain.DNDState {
+	var dnd domain.DNDState
+	if err := json.Unmarshal([]byte(raw), &dnd); err != nil {
+		return nil
+	}
+	if !dnd.Active(time.Now()) {
+		return nil
+	}
+	return &dnd
+}
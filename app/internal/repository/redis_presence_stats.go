@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"hash/crc32"
+	"strconv"
+	"time"
+
+	"social-app/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisPresenceStatsRepository stores presence statistics in Redis
+type RedisPresenceStatsRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+// NewRedisPresenceStatsRepository creates a new RedisPresenceStatsRepository with the given Redis client
+func NewRedisPresenceStatsRepository(client *redis.Client) domain.PresenceStatsRepository {
+	return &RedisPresenceStatsRepository{
+		client: client,
+		ctx:    context.Background(),
+	}
+}
+
+// GetStatusCounts counts users per status across all stats shards in one pipeline.
+// The status scripts keep every user in the set of their status, scored by when
+// the status key expires, so users whose key expired silently are not counted.
+func (r *RedisPresenceStatsRepository) GetStatusCounts() (map[domain.Status]int64, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	cmds := make(map[domain.Status][]*redis.IntCmd, len(domain.Statuses))
+	_, err := r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for _, status := range domain.Statuses {
+			for shard := 0; shard < domain.PresenceStatsShards; shard++ {
+				key := domain.GetPresenceStatsKey(shard, status)
+				pipe.ZRemRangeByScore(r.ctx, key, "-inf", now)
+				cmds[status] = append(cmds[status], pipe.ZCount(r.ctx, key, "("+now, "+inf"))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[domain.Status]int64, len(cmds))
+	for status, shardCmds := range cmds {
+		for _, cmd := range shardCmds {
+			counts[status] += cmd.Val()
+		}
+	}
+	return counts, nil
+}
+
+// CountDailyActive approximately counts unique users active on a UTC day (HyperLogLog)
+func (r *RedisPresenceStatsRepository) CountDailyActive(day time.Time) (int64, error) {
+	return r.client.PFCount(r.ctx, domain.GetDailyActiveKey(day)).Result()
+}
+
+// presenceStatsShard picks the stats shard of a user
+func presenceStatsShard(userID string) int {
+	return int(crc32.ChecksumIEEE([]byte(userID)) % domain.PresenceStatsShards)
+}
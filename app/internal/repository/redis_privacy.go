@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"encoding/json"
+
+	"social-app/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SetPrivacySettings stores user's privacy settings (no expiry)
+func (r *RedisUserStatusRepository) SetPrivacySettings(userID string, settings domain.PrivacySettings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, domain.GetUserPrivacyKey(userID), data, 0).Err()
+}
+
+// GetPrivacySettings gets user's privacy settings, returning nil if none are configured
+func (r *RedisUserStatusRepository) GetPrivacySettings(userID string) (*domain.PrivacySettings, error) {
+	raw, err := r.client.Get(r.ctx, domain.GetUserPrivacyKey(userID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var settings domain.PrivacySettings
+	if err := json.Unmarshal([]byte(raw), &settings); err != nil {
+		return nil, nil
+	}
+	return &settings, nil
+}
+
+// GetMultiplePrivacySettings gets privacy settings of multiple users using MGET
+func (r *RedisUserStatusRepository) GetMultiplePrivacySettings(userIDs []string) (map[string]*domain.PrivacySettings, error) {
+	settingsByUser := make(map[string]*domain.PrivacySettings)
+	if len(userIDs) == 0 {
+		return settingsByUser, nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = domain.GetUserPrivacyKey(userID)
+	}
+
+	values, err := r.mget(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		raw, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		var settings domain.PrivacySettings
+		if err := json.Unmarshal([]byte(raw), &settings); err != nil {
+			continue
+		}
+		settingsByUser[userID] = &settings
+	}
+
+	return settingsByUser, nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"encoding/json"
+
+	"social-app/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SetQuietHours stores user's quiet hours schedule (no expiry)
+func (r *RedisUserStatusRepository) SetQuietHours(userID string, schedule domain.QuietHoursSchedule) error {
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, domain.GetQuietHoursKey(userID), data, 0).Err()
+}
+
+// GetQuietHours gets user's quiet hours schedule, returning nil if none is configured
+func (r *RedisUserStatusRepository) GetQuietHours(userID string) (*domain.QuietHoursSchedule, error) {
+	raw, err := r.client.Get(r.ctx, domain.GetQuietHoursKey(userID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule domain.QuietHoursSchedule
+	if err := json.Unmarshal([]byte(raw), &schedule); err != nil {
+		return nil, nil
+	}
+	return &schedule, nil
+}
+
+// GetMultipleQuietHours gets quiet hours schedules of multiple users using MGET
+func (r *RedisUserStatusRepository) GetMultipleQuietHours(userIDs []string) (map[string]*domain.QuietHoursSchedule, error) {
+	schedules := make(map[string]*domain.QuietHoursSchedule)
+	if len(userIDs) == 0 {
+		return schedules, nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = domain.GetQuietHoursKey(userID)
+	}
+
+	values, err := r.mget(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		raw, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		var schedule domain.QuietHoursSchedule
+		if err := json.Unmarshal([]byte(raw), &schedule); err != nil {
+			continue
+		}
+		schedules[userID] = &schedule
+	}
+
+	return schedules, nil
+}
+
+// DeleteQuietHours removes user's quiet hours schedule
+func (r *RedisUserStatusRepository) DeleteQuietHours(userID string) error {
+	return r.client.Del(r.ctx, domain.GetQuietHoursKey(userID)).Err()
+}
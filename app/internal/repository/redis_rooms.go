@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"social-app/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisRoomRepository stores room presence in Redis
+type RedisRoomRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+// NewRedisRoomRepository creates a new RedisRoomRepository with the given Redis client
+func NewRedisRoomRepository(client *redis.Client) domain.RoomRepository {
+	return &RedisRoomRepository{
+		client: client,
+		ctx:    context.Background(),
+	}
+}
+
+// joinRoomScript adds ARGV[1] to the room members (KEYS[1]) and ARGV[2] to the
+// user's rooms (KEYS[2]) unless the room already holds ARGV[3] members, and keeps
+// both sets for ARGV[4] ms (heartbeats extend them). Rejoining is always allowed.
+// A full room first drops members who are no longer present: their status key holds
+// a status that is not present, or it expired and the backup status the expiry
+// worker is about to transition from is not present either.
+// Returns 1 when present, 0 when the room is full.
+var joinRoomScript = redis.NewScript(`
+local present = ` + luaTable(statusSet(domain.Status.IsPresent)) + `
+
+local function is_present(user)
+	local status = redis.call('GET', '` + domain.UserStatusKeyPrefix + `' .. user)
+		or redis.call('GET', '` + lastStatusKeyPrefix + `' .. user)
+	return status and present[status]
+end
+
+if redis.call('SISMEMBER', KEYS[1], ARGV[1]) == 0 and redis.call('SCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	for _, member in ipairs(redis.call('SMEMBERS', KEYS[1])) do
+		if not is_present(member) then
+			redis.call('SREM', KEYS[1], member)
+			redis.call('SREM', '` + domain.UserRoomsKeyPrefix + `' .. member, ARGV[2])
+		end
+	end
+	if redis.call('SCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+		return 0
+	end
+end
+redis.call('SADD', KEYS[1], ARGV[1])
+redis.call('SADD', KEYS[2], ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+redis.call('PEXPIRE', KEYS[2], ARGV[4])
+return 1
+`)
+
+// JoinRoom adds user to a room's members and the room to the user's rooms, checking
+// the member cap in the same step so concurrent joins cannot overfill the room.
+// Both sets expire after ttl unless the user's heartbeats extend them.
+func (r *RedisRoomRepository) JoinRoom(roomID, userID string, maxMembers int, ttl time.Duration) error {
+	keys := []string{domain.GetRoomMembersKey(roomID), domain.GetUserRoomsKey(userID)}
+
+	joined, err := joinRoomScript.Run(r.ctx, r.client, keys, userID, roomID, maxMembers, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if joined == 0 {
+		return domain.ErrRoomFull
+	}
+	return nil
+}
+
+// LeaveRoom removes user from a room
+func (r *RedisRoomRepository) LeaveRoom(roomID, userID string) error {
+	return r.RemoveRoomMembers(roomID, []string{userID})
+}
+
+// GetRoomMembers gets IDs of users present in a room
+func (r *RedisRoomRepository) GetRoomMembers(roomID string) ([]string, error) {
+	return r.client.SMembers(r.ctx, domain.GetRoomMembersKey(roomID)).Result()
+}
+
+// RemoveRoomMembers removes several users from a room in one transaction
+func (r *RedisRoomRepository) RemoveRoomMembers(roomID string, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	_, err := r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.SRem(r.ctx, domain.GetRoomMembersKey(roomID), toInterfaces(userIDs)...)
+		for _, userID := range userIDs {
+			pipe.SRem(r.ctx, domain.GetUserRoomsKey(userID), roomID)
+		}
+		return nil
+	})
+	return err
+}
+
+// LeaveAllRooms removes user from every room they are present in
+func (r *RedisRoomRepository) LeaveAllRooms(userID string) error {
+	roomsKey := domain.GetUserRoomsKey(userID)
+
+	roomIDs, err := r.client.SMembers(r.ctx, roomsKey).Result()
+	if err != nil {
+		return err
+	}
+	if len(roomIDs) == 0 {
+		return nil
+	}
+
+	_, err = r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for _, roomID := range roomIDs {
+			pipe.SRem(r.ctx, domain.GetRoomMembersKey(roomID), userID)
+		}
+		pipe.SRem(r.ctx, roomsKey, toInterfaces(roomIDs)...)
+		return nil
+	})
+	return err
+}
+
+// toInterfaces converts strings to variadic command arguments
+func toInterfaces(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
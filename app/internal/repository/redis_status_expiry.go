@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"social-app/internal/domain"
+)
+
+// SubscribeExpiredUserStatus streams users whose status key expired or whose
+// do-not-disturb period reached its deadline.
+// Requires notify-keyspace-events with "Ex" (see redis.conf).
+func (r *RedisUserStatusRepository) SubscribeExpiredUserStatus(ctx context.Context) (<-chan domain.PresenceExpiry, error) {
+	channel := fmt.Sprintf("__keyevent@%d__:expired", r.client.Options().DB)
+
+	pubsub := r.client.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	expiries := make(chan domain.PresenceExpiry)
+	go func() {
+		defer close(expiries)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var expiry domain.PresenceExpiry
+				switch {
+				case strings.HasPrefix(msg.Payload, domain.UserStatusKeyPrefix):
+					expiry.UserID = strings.TrimPrefix(msg.Payload, domain.UserStatusKeyPrefix)
+				case strings.HasPrefix(msg.Payload, domain.UserDNDKeyPrefix):
+					expiry.UserID = strings.TrimPrefix(msg.Payload, domain.UserDNDKeyPrefix)
+					expiry.DND = true
+				default:
+					continue
+				}
+				select {
+				case expiries <- expiry:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return expiries, nil
+}
+
+// ExpireUserStatus eagerly performs the auto-transition after a status key expired.
+// Only the instance that claims the expiry lock transitions; others get a nil event.
+// The transition advances the backup status in the same script, so an instance that
+// claims the released lock for the same expiry finds nothing changed. Releasing it
+// lets the user's next expiry (e.g. a status aggregated from a session about to end)
+// be handled too.
+func (r *RedisUserStatusRepository) ExpireUserStatus(userID string) (*domain.StatusEvent, error) {
+	lockKey := domain.GetStatusExpiryLockKey(userID)
+	claimed, err := r.client.SetNX(r.ctx, lockKey, 1, domain.StatusExpiryLockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, nil
+	}
+	defer r.client.Del(r.ctx, lockKey)
+
+	resolved, err := r.resolveStatus(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolved.status == resolved.previous {
+		return nil, nil
+	}
+
+	return &domain.StatusEvent{
+		UserID:    userID,
+		OldStatus: resolved.previous,
+		NewStatus: resolved.status,
+		Cause:     resolved.cause,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// PublishStatusEvent publishes a status change to the status events channel
+func (r *RedisUserStatusRepository) PublishStatusEvent(event domain.StatusEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Publish(r.ctx, domain.StatusEventsChannel, data).Err()
+}
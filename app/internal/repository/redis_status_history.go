@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"social-app/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// GetStatusHistory returns a page of user's status changes, newest first.
+// Entries are appended atomically by the status scripts (see set_status_with_backup).
+func (r *RedisUserStatusRepository) GetStatusHistory(userID string, query domain.StatusHistoryQuery) ([]domain.StatusHistoryEntry, error) {
+	end := "+"
+	if query.To != nil {
+		end = strconv.FormatInt(query.To.UnixMilli(), 10)
+	}
+	if query.Cursor != "" {
+		end = "(" + query.Cursor
+	}
+
+	start := "-"
+	if query.From != nil {
+		start = strconv.FormatInt(query.From.UnixMilli(), 10)
+	}
+
+	messages, err := r.client.XRevRangeN(r.ctx, domain.GetStatusHistoryKey(userID), end, start, query.Limit).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]domain.StatusHistoryEntry, 0, len(messages))
+	for _, msg := range messages {
+		entry := domain.StatusHistoryEntry{
+			ID:          msg.ID,
+			OldStatus:   domain.Status(streamField(msg, "old")),
+			NewStatus:   domain.Status(streamField(msg, "new")),
+			ShownStatus: domain.Status(streamField(msg, "shown")),
+			Cause:       streamField(msg, "cause"),
+			SessionID:   streamField(msg, "session"),
+			Timestamp:   streamIDTime(msg.ID),
+		}
+		if entry.ShownStatus == "" {
+			// Recorded before the shown status was stored: those entries held shown statuses
+			entry.ShownStatus = entry.NewStatus
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// streamField reads a string field of a stream entry
+func streamField(msg redis.XMessage, field string) string {
+	value, _ := msg.Values[field].(string)
+	return value
+}
+
+// streamIDTime extracts the millisecond timestamp part of a stream entry ID
+func streamIDTime(id string) time.Time {
+	millis, _ := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	return time.UnixMilli(millis)
+}
@@ -0,0 +1,419 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"social-app/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Status transitions run as server-side Lua scripts so each heartbeat, explicit set
+// and expiry transition is a single atomic step. All scripts share the same layout:
+//
+//	KEYS[1] user:status:{id}        KEYS[2] user:last_status:{id}
+//	KEYS[3] user:sessions:{id}      KEYS[4] user:last_seen:{id}
+//	KEYS[5] user:session:{id}:{sid} KEYS[6] user:status_history:{id}
+//	KEYS[7] user:status_version:{id} KEYS[8] user:dnd:{id}
+//
+//	ARGV[1] session key prefix      ARGV[2] backup TTL extension (ms)
+//	ARGV[3] session ID              ARGV[4] session TTL (ms; DND TTL for setDND, 0 = until cleared)
+//	ARGV[5] session index TTL (ms)  ARGV[6] now (unix ms)
+//	ARGV[7] now (RFC3339)           ARGV[8] last seen TTL (ms)
+//	ARGV[9] script payload          ARGV[10] history max entries
+//	ARGV[11] history max age (ms)   ARGV[12] expected version ('' = unconditional)
+//	ARGV[13] user ID                ARGV[14] presence stats key prefix of the user's shard
+//	ARGV[15] daily active HLL key   ARGV[16] daily active TTL (ms)
+//	ARGV[17] heartbeat recency key of the user's shard
+//
+// The transition table and status TTLs come from the presence policy and are
+// rendered into the scripts, so each policy gets its own script SHAs.
+// Session keys other than KEYS[5], the stats keys and the heartbeat recency key are
+// derived from ARGV, and room keys are embedded, so the scripts assume a single
+// Redis node (no cluster slot checks).
+type statusScripts struct {
+	get        *redis.Script
+	setSession *redis.Script
+	heartbeat  *redis.Script
+	endSession *redis.Script
+	setDND     *redis.Script
+	endDND     *redis.Script
+}
+
+// unchangedTTLTolerance is how far a status key's remaining TTL may drift from a
+// recomputed one before the scripts rewrite it
+const unchangedTTLTolerance = time.Second
+
+// newStatusScripts builds the status transition scripts for a presence policy
+func newStatusScripts(policy domain.PresencePolicy) *statusScripts {
+	ttls := make(map[domain.Status]int, len(policy.StatusTTLs))
+	for status, ttl := range policy.StatusTTLs {
+		ttls[status] = int(ttl.Milliseconds())
+	}
+
+	prelude := `
+local precedence = ` + luaTable(domain.StatusPrecedence) + `
+local transitions = ` + luaTable(policy.Transitions) + `
+local status_ttl = ` + luaTable(ttls) + `
+local live_window = ` + fmt.Sprint(policy.LiveWindow().Milliseconds()) + `
+local present = ` + luaTable(statusSet(domain.Status.IsPresent)) + `
+local activity = ` + luaTable(statusSet(domain.Status.IsActivity)) + `
+local shows_dnd = ` + luaTable(statusSet(domain.Status.ShowsDND)) + `
+local public = ` + luaTable(publicStatuses()) + `
+
+-- bump_version increments the presence version once per script run. The version
+-- outlives status keys (last seen TTL), so it never restarts while clients hold it.
+local version_bumped = false
+local function bump_version()
+	if not version_bumped then
+		redis.call('INCR', KEYS[7])
+		redis.call('PEXPIRE', KEYS[7], ARGV[8])
+		version_bumped = true
+	end
+end
+
+local function current_version()
+	return redis.call('GET', KEYS[7]) or '0'
+end
+
+-- count_status records the user in the presence stats set of the status others see,
+-- scored by when that status ends, so counts stay right when keys expire silently.
+-- Expired entries are pruned when the stats are read.
+local function count_status(status, ttl, changed)
+	if changed then
+		for other in pairs(precedence) do
+			if other ~= status then
+				redis.call('ZREM', ARGV[14] .. other, ARGV[13])
+			end
+		end
+	end
+	if status == '` + string(domain.StatusDND) + `' then
+		local dnd_ttl = redis.call('PTTL', KEYS[8])
+		if dnd_ttl > 0 and dnd_ttl < ttl then
+			ttl = dnd_ttl
+		end
+	end
+	redis.call('ZADD', ARGV[14] .. status, tonumber(ARGV[6]) + ttl, ARGV[13])
+end
+
+-- shown returns what others see for a presence status: an active do-not-disturb
+-- period (KEYS[8], expires at its deadline) overlays the statuses that show DND only
+local function shown(status)
+	if shows_dnd[status] and redis.call('EXISTS', KEYS[8]) == 1 then
+		return '` + string(domain.StatusDND) + `'
+	end
+	return status
+end
+
+-- record_change appends a change to the capped history stream: the actual status
+-- before and after, and the status others see after it
+local function record_change(old, new, after, cause)
+	bump_version()
+	redis.call('XADD', KEYS[6], 'MAXLEN', '~', ARGV[10], '*', 'old', old, 'new', new, 'shown', after, 'cause', cause, 'session', ARGV[3])
+	redis.call('XTRIM', KEYS[6], 'MINID', '~', tonumber(ARGV[6]) - tonumber(ARGV[11]))
+	redis.call('PEXPIRE', KEYS[6], ARGV[11])
+end
+
+-- last_recorded returns the shown status of the newest history entry, if any.
+-- Entries written before the shown field existed recorded shown statuses as new.
+local function last_recorded()
+	local entry = redis.call('XREVRANGE', KEYS[6], '+', '-', 'COUNT', 1)[1]
+	if not entry then
+		return false
+	end
+	local fields, new = entry[2], false
+	for i = 1, #fields, 2 do
+		if fields[i] == 'shown' then
+			return fields[i + 1]
+		elseif fields[i] == 'new' then
+			new = fields[i + 1]
+		end
+	end
+	return new
+end
+
+-- set_status_with_backup sets status and maintains backup for auto-transition.
+-- Nothing is written when the status key already holds the status with about the
+-- same TTL (e.g. a read aggregating unchanged sessions).
+-- Status changes are appended to the capped per-user history stream.
+local function set_status_with_backup(status, ttl, cause)
+	local current = redis.call('GET', KEYS[1])
+	if current == status and math.abs(redis.call('PTTL', KEYS[1]) - ttl) < ` + fmt.Sprint(unchangedTTLTolerance.Milliseconds()) + ` then
+		return
+	end
+	local old = current or redis.call('GET', KEYS[2]) or '` + string(domain.StatusUnknown) + `'
+	redis.call('SET', KEYS[1], status, 'PX', ttl)
+	redis.call('SET', KEYS[2], status, 'PX', ttl + tonumber(ARGV[2]))
+	count_status(shown(status), ttl, shown(old) ~= shown(status))
+	if old ~= status then
+		record_change(old, status, shown(status), cause)
+	end
+end
+
+-- aggregate writes the effective status of all live sessions into the status key.
+-- A status the user chose manually wins over derived ones; within each group the
+-- usual precedence applies. The key lives as long as the longest-lived session
+-- holding the winning status. Returns false when the user has no live sessions.
+local function aggregate(cause)
+	local effective, rank, ttl = false, 0, 0
+	for _, id in ipairs(redis.call('SMEMBERS', KEYS[3])) do
+		local key = ARGV[1] .. id
+		local raw = redis.call('GET', key)
+		if not raw then
+			redis.call('SREM', KEYS[3], id)
+		else
+			local ok, session = pcall(cjson.decode, raw)
+			if ok then
+				local r = precedence[session.status] or 0
+				if session.source == '` + domain.SourceManual + `' then
+					r = r + 100
+				end
+				local pttl = redis.call('PTTL', key)
+				if r > rank then
+					effective, rank, ttl = session.status, r, pttl
+				elseif r == rank and pttl > ttl then
+					ttl = pttl
+				end
+			end
+		end
+	end
+	if effective and ttl > 0 then
+		set_status_with_backup(effective, ttl, cause)
+	end
+	return effective
+end
+
+-- expire auto-transitions an expired status key based on the backup status.
+-- A status written meanwhile is left alone, so stale states cannot be resurrected.
+-- Without a transition the backup is dropped as well, so the change to unknown is
+-- seen exactly once, like every other transition.
+local function expire()
+	local current = redis.call('GET', KEYS[1])
+	if current then
+		return current
+	end
+	local last = redis.call('GET', KEYS[2])
+	local target = last and transitions[last]
+	if not target then
+		if last then
+			redis.call('DEL', KEYS[2])
+			bump_version()
+		end
+		return '` + string(domain.StatusUnknown) + `'
+	end
+	set_status_with_backup(target, status_ttl[target], '` + domain.CauseExpired + `')
+	return target
+end
+
+-- effective returns the aggregated session status, falling back to the status key
+local function effective(cause)
+	return aggregate(cause) or expire()
+end
+
+-- dnd_changed records what others see after a do-not-disturb period started or
+-- ended (the actual status stays the same) and moves the user to the matching
+-- presence stats set
+local function dnd_changed(before, status, cause)
+	local after = shown(status)
+	if before ~= after then
+		record_change(status, status, after, cause)
+		local ttl = redis.call('PTTL', KEYS[1])
+		if ttl > 0 then
+			count_status(after, ttl, true)
+		end
+	end
+end
+
+-- write_session stores a session record and updates index, last seen, daily actives
+-- and the heartbeat recency set, trimming users who went quiet longer than the live window.
+-- Rooms the user is present in live for another live window, like the user's presence.
+-- seen_at (unix ms) is when the user was last active; last seen only moves forward.
+local function write_session(session, ttl, seen_at)
+	redis.call('SET', KEYS[5], cjson.encode(session), 'PX', ttl)
+	redis.call('SADD', KEYS[3], ARGV[3])
+	redis.call('PEXPIRE', KEYS[3], ARGV[5])
+	if present[session.status] then
+		redis.call('PFADD', ARGV[15], ARGV[13])
+		redis.call('PEXPIRE', ARGV[15], ARGV[16])
+		redis.call('ZADD', ARGV[17], ARGV[6], ARGV[13])
+		redis.call('ZREMRANGEBYSCORE', ARGV[17], '-inf', '(' .. (tonumber(ARGV[6]) - live_window))
+		local rooms_key = '` + domain.UserRoomsKeyPrefix + `' .. ARGV[13]
+		if redis.call('PEXPIRE', rooms_key, live_window) == 1 then
+			for _, room in ipairs(redis.call('SMEMBERS', rooms_key)) do
+				redis.call('PEXPIRE', '` + domain.RoomMembersKeyPrefix + `' .. room, live_window)
+			end
+		end
+	end
+	if public[session.status] == session.status then
+		-- Invisible activity must not move "last seen", otherwise it reveals the user
+		local last_seen = tonumber(redis.call('GET', KEYS[4]) or '0') or 0
+		redis.call('SET', KEYS[4], string.format('%d', math.max(last_seen, seen_at)), 'PX', ARGV[8])
+	end
+end
+`
+
+	return &statusScripts{
+		// get returns {effective status, previous backup status, version, cause}, where
+		// the cause tells whether live sessions or the expiry transition produced the status
+		get: redis.NewScript(prelude + `
+local previous = redis.call('GET', KEYS[2]) or '` + string(domain.StatusUnknown) + `'
+local status, cause = aggregate('` + domain.CauseSessionExpired + `'), '` + domain.CauseSessionExpired + `'
+if not status then
+	status, cause = expire(), '` + domain.CauseExpired + `'
+end
+return {status, previous, current_version(), cause}
+`),
+
+		// setSession stores an explicit session status (ARGV[9] = session JSON) and
+		// returns {'ok', new version}, or {'conflict', current version} when ARGV[12]
+		// does not match the current version
+		setSession: redis.NewScript(prelude + `
+if ARGV[12] ~= '' and current_version() ~= ARGV[12] then
+	return {'conflict', current_version()}
+end
+local session = cjson.decode(ARGV[9])
+if session.device_type == '' then
+	-- Keep the device type the session registered with
+	session.device_type = '` + domain.DeviceUnknown + `'
+	local existing = redis.call('GET', KEYS[5])
+	if existing then
+		local ok, previous = pcall(cjson.decode, existing)
+		if ok and previous.device_type and previous.device_type ~= '' then
+			session.device_type = previous.device_type
+		end
+	end
+end
+write_session(session, ARGV[4], tonumber(ARGV[6]))
+bump_version()
+local status = effective('` + domain.CauseExplicit + `')
+-- Explicitly choosing a status ends do-not-disturb in the same step
+local before = shown(status)
+if redis.call('DEL', KEYS[8]) == 1 then
+	dnd_changed(before, status, '` + domain.CauseExplicit + `')
+end
+return {'ok', current_version()}
+`),
+
+		// heartbeat refreshes a session and returns {session status, effective status}.
+		// ARGV[9] is JSON {device_type, status, idle_ms} where status is derived from client activity.
+		// Manual statuses only get their liveness refreshed; automatic sessions take the
+		// activity status; unknown or expired sessions are registered with it.
+		heartbeat: redis.NewScript(prelude + `
+local ttl = tonumber(ARGV[4])
+local beat = cjson.decode(ARGV[9])
+local session
+local raw = redis.call('GET', KEYS[5])
+if raw then
+	local ok, decoded = pcall(cjson.decode, raw)
+	if ok then
+		session = decoded
+	end
+end
+
+if not session then
+	session = {session_id = ARGV[3], device_type = '` + domain.DeviceUnknown + `', status = beat.status, source = '` + domain.SourceAuto + `'}
+elseif session.source == '` + domain.SourceManual + `' then
+	-- Keep the user's choice; never shorten a longer status TTL
+	ttl = math.max(ttl, redis.call('PTTL', KEYS[5]))
+elseif activity[session.status] then
+	session.status = beat.status
+else
+	return {session.status, effective('` + domain.CauseSessionExpired + `')}
+end
+
+if beat.device_type ~= '' then
+	session.device_type = beat.device_type
+end
+session.last_heartbeat = ARGV[7]
+write_session(session, ttl, tonumber(ARGV[6]) - (tonumber(beat.idle_ms) or 0))
+return {session.status, effective('` + domain.CauseHeartbeat + `')}
+`),
+
+		// endSession removes a session (logout) and returns the effective status.
+		// Logging out of the last session takes the user offline.
+		endSession: redis.NewScript(prelude + `
+redis.call('DEL', KEYS[5])
+redis.call('SREM', KEYS[3], ARGV[3])
+bump_version()
+local status = aggregate('` + domain.CauseLogout + `')
+if not status then
+	set_status_with_backup('` + string(domain.StatusOffline) + `', status_ttl['` + string(domain.StatusOffline) + `'], '` + domain.CauseLogout + `')
+	status = '` + string(domain.StatusOffline) + `'
+end
+return status
+`),
+
+		// setDND starts do-not-disturb (ARGV[9] = DND JSON, ARGV[4] = TTL until the
+		// deadline or 0) and returns {'ok', new version}, or {'conflict', current version}
+		// when ARGV[12] does not match the current version
+		setDND: redis.NewScript(prelude + `
+if ARGV[12] ~= '' and current_version() ~= ARGV[12] then
+	return {'conflict', current_version()}
+end
+local status = effective('` + domain.CauseSessionExpired + `')
+local before = shown(status)
+if tonumber(ARGV[4]) > 0 then
+	redis.call('SET', KEYS[8], ARGV[9], 'PX', ARGV[4])
+else
+	redis.call('SET', KEYS[8], ARGV[9])
+end
+bump_version()
+dnd_changed(before, status, '` + domain.CauseDNDStarted + `')
+return {'ok', current_version()}
+`),
+
+		// endDND ends do-not-disturb (ARGV[9] = cause) and returns {shown before, shown after}.
+		// When it ended at its deadline the key is already gone, so the end is recorded
+		// only if the history still shows DND; repeated expiry events record it once.
+		endDND: redis.NewScript(prelude + `
+local status = effective('` + domain.CauseSessionExpired + `')
+local before = shown(status)
+if redis.call('DEL', KEYS[8]) == 1 then
+	bump_version()
+elseif ARGV[9] == '` + domain.CauseDNDEnded + `' and last_recorded() == '` + string(domain.StatusDND) + `' then
+	before = '` + string(domain.StatusDND) + `'
+end
+dnd_changed(before, status, ARGV[9])
+return {before, shown(status)}
+`),
+	}
+}
+
+// statusSet returns the statuses that have an attribute, for rendering into the scripts
+func statusSet(has func(domain.Status) bool) map[domain.Status]bool {
+	set := make(map[domain.Status]bool)
+	for _, status := range domain.Statuses {
+		if has(status) {
+			set[status] = true
+		}
+	}
+	return set
+}
+
+// publicStatuses maps every status to what other users see for it
+func publicStatuses() map[domain.Status]domain.Status {
+	public := make(map[domain.Status]domain.Status, len(domain.Statuses))
+	for _, status := range domain.Statuses {
+		public[status] = status.Public()
+	}
+	return public
+}
+
+// luaTable renders a string-keyed map as a Lua table literal with sorted keys,
+// so script SHAs are stable across restarts
+func luaTable[K ~string, V ~int | ~string | ~bool](m map[K]V) string {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	entries := make([]string, len(keys))
+	for i, key := range keys {
+		entries[i] = fmt.Sprintf("[%q]=%#v", string(key), m[key])
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
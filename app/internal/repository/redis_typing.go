@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"social-app/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisTypingRepository stores typing indicators in Redis
+type RedisTypingRepository struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+// NewRedisTypingRepository creates a new RedisTypingRepository with the given Redis client
+func NewRedisTypingRepository(client *redis.Client) domain.TypingRepository {
+	return &RedisTypingRepository{
+		client: client,
+		ctx:    context.Background(),
+	}
+}
+
+// StartTyping marks user as typing in a conversation until ttl passes. Each user
+// carries its own expiry as score; the key itself lives as long as the newest one.
+func (r *RedisTypingRepository) StartTyping(conversationID, userID string, ttl time.Duration) error {
+	key := domain.GetTypingKey(conversationID)
+	expiresAt := time.Now().Add(ttl).UnixMilli()
+
+	_, err := r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(r.ctx, key, redis.Z{Score: float64(expiresAt), Member: userID})
+		pipe.PExpire(r.ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+// StopTyping clears user's typing indicator, reporting whether it was set
+func (r *RedisTypingRepository) StopTyping(conversationID, userID string) (bool, error) {
+	removed, err := r.client.ZRem(r.ctx, domain.GetTypingKey(conversationID), userID).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+// GetTypingUsers gets users currently typing in a conversation, dropping expired ones
+func (r *RedisTypingRepository) GetTypingUsers(conversationID string) ([]string, error) {
+	key := domain.GetTypingKey(conversationID)
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	var typing *redis.StringSliceCmd
+	_, err := r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(r.ctx, key, "-inf", now)
+		typing = pipe.ZRange(r.ctx, key, 0, -1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return typing.Val(), nil
+}
+
+// PublishTypingEvent publishes a typing change to its conversation's typing events channel
+func (r *RedisTypingRepository) PublishTypingEvent(event domain.TypingEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Publish(r.ctx, domain.GetTypingEventsChannel(event.ConversationID), data).Err()
+}
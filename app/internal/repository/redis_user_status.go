@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"social-app/internal/domain"
@@ -10,154 +13,434 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// statusBackupTTL is how much longer the backup status outlives the status key
+const statusBackupTTL = 24 * time.Hour
+
 type RedisUserStatusRepository struct {
-	client *redis.Client
-	ctx    context.Context
+	client  *redis.Client
+	ctx     context.Context
+	scripts *statusScripts
+	history domain.StatusHistoryRetention
 }
 
-func NewRedisUserStatusRepository(client *redis.Client) domain.UserStatusRepository {
+func NewRedisUserStatusRepository(client *redis.Client, policy domain.PresencePolicy, history domain.StatusHistoryRetention) domain.UserStatusRepository {
 	return &RedisUserStatusRepository{
-		client: client,
-		ctx:    context.Background(),
+		client:  client,
+		ctx:     context.Background(),
+		scripts: newStatusScripts(policy),
+		history: history,
 	}
 }
 
-// SetUserStatus sets user status in Redis with TTL
-func (r *RedisUserStatusRepository) SetUserStatus(userID, status string, ttl time.Duration) error {
-	return r.setStatusWithBackup(userID, status, ttl)
+// lastStatusKeyPrefix is the backup status used by auto-transitions
+const lastStatusKeyPrefix = "user:last_status:"
+
+// getLastStatusKey returns Redis key for the backup status used by auto-transitions
+func getLastStatusKey(userID string) string {
+	return lastStatusKeyPrefix + userID
 }
 
-// setStatusWithBackup sets status and maintains backup for auto-transition
-func (r *RedisUserStatusRepository) setStatusWithBackup(userID, status string, ttl time.Duration) error {
-	key := domain.GetUserStatusKey(userID)
-	lastStatusKey := fmt.Sprintf("user:last_status:%s", userID)
+// SetUserStatus sets user status in Redis with TTL on the default session
+func (r *RedisUserStatusRepository) SetUserStatus(userID string, status domain.Status, ttl time.Duration) error {
+	return r.SetSessionStatus(userID, domain.SessionPresence{
+		SessionID: domain.DefaultSessionID,
+		Status:    status,
+		Source:    domain.SourceAuto,
+	}, ttl)
+}
 
-	// Use pipeline for atomic operations
-	pipe := r.client.Pipeline()
+// SetSessionStatus stores presence of a single session and re-aggregates the user status atomically
+func (r *RedisUserStatusRepository) SetSessionStatus(userID string, session domain.SessionPresence, ttl time.Duration) error {
+	_, err := r.CompareAndSetSessionStatus(userID, session, ttl, domain.AnyVersion)
+	return err
+}
 
-	// Set current status with TTL
-	pipe.Set(r.ctx, key, status, ttl)
+// CompareAndSetSessionStatus stores presence of a single session only if the user's presence
+// version still equals version (domain.AnyVersion skips the check). Returns the new version,
+// or domain.ErrVersionConflict if the presence changed meanwhile.
+func (r *RedisUserStatusRepository) CompareAndSetSessionStatus(userID string, session domain.SessionPresence, ttl time.Duration, version int64) (int64, error) {
+	if session.LastHeartbeat.IsZero() {
+		session.LastHeartbeat = time.Now()
+	}
 
-	// Set backup status (longer TTL for transition logic)
-	backupTTL := ttl + (24 * time.Hour) // Keep backup longer than main key
-	pipe.Set(r.ctx, lastStatusKey, status, backupTTL)
+	data, err := json.Marshal(session)
+	if err != nil {
+		return 0, err
+	}
 
-	_, err := pipe.Exec(r.ctx)
-	return err
+	expected := ""
+	if version != domain.AnyVersion {
+		expected = strconv.FormatInt(version, 10)
+	}
+
+	values, err := r.runStatusScript(r.scripts.setSession, userID, session.SessionID, ttl, string(data), expected).StringSlice()
+	if err != nil {
+		return 0, err
+	}
+	if len(values) != 2 {
+		return 0, fmt.Errorf("unexpected set status reply: %v", values)
+	}
+	if values[0] != "ok" {
+		return 0, domain.ErrVersionConflict
+	}
+	return strconv.ParseInt(values[1], 10, 64)
 }
 
-// GetUserStatus gets user status from Redis with auto-transition logic
-func (r *RedisUserStatusRepository) GetUserStatus(userID string) (string, error) {
-	key := domain.GetUserStatusKey(userID)
-	result := r.client.Get(r.ctx, key)
+// RefreshSessionTTL refreshes a single session (heartbeat) without touching other sessions.
+// Automatic sessions take the activity-derived status (online or away); unknown or expired
+// sessions are registered with it. Returns the session's status after the refresh
+// and the effective user status.
+func (r *RedisUserStatusRepository) RefreshSessionTTL(userID string, client domain.ClientSession, status domain.Status, idle time.Duration, ttl time.Duration) (domain.Status, domain.Status, error) {
+	payload, err := heartbeatPayload(client, status, idle)
+	if err != nil {
+		return "", "", err
+	}
+
+	values, err := r.runStatusScript(r.scripts.heartbeat, userID, client.SessionID, ttl, payload, "").StringSlice()
+	if err != nil {
+		return "", "", err
+	}
+	session, effective := heartbeatStatus(values)
+	return session, effective, nil
+}
+
+// RefreshSessionTTLs refreshes many sessions with the heartbeat script in a single
+// pipeline, so each one gets the same away→online handling as RefreshSessionTTL.
+// Results follow the order of refreshes; a failed refresh does not fail the others.
+// If Redis lost the script cache the script is loaded and the pipeline retried once.
+func (r *RedisUserStatusRepository) RefreshSessionTTLs(refreshes []domain.SessionRefresh) ([]domain.SessionRefreshResult, error) {
+	results := make([]domain.SessionRefreshResult, len(refreshes))
+	if len(refreshes) == 0 {
+		return results, nil
+	}
+
+	payloads := make([]string, len(refreshes))
+	for i, refresh := range refreshes {
+		payload, err := heartbeatPayload(refresh.Session, refresh.Status, refresh.Idle)
+		if err != nil {
+			return nil, err
+		}
+		payloads[i] = payload
+	}
+
+	cmds := make([]*redis.Cmd, len(refreshes))
+	queue := func(pipe redis.Pipeliner) error {
+		for i, refresh := range refreshes {
+			keys, args := r.statusScriptLayout(refresh.UserID, refresh.Session.SessionID, refresh.TTL, payloads[i], "")
+			cmds[i] = r.scripts.heartbeat.EvalSha(r.ctx, pipe, keys, args...)
+		}
+		return nil
+	}
 
-	if result.Err() == redis.Nil {
-		// Key doesn't exist - check for auto-transition
-		return r.handleExpiredStatus(userID)
+	_, err := r.client.Pipelined(r.ctx, queue)
+	if redis.HasErrorPrefix(err, "NOSCRIPT") {
+		if err := r.scripts.heartbeat.Load(r.ctx, r.client).Err(); err != nil {
+			return nil, err
+		}
+		_, err = r.client.Pipelined(r.ctx, queue)
+	}
+	// Errors Redis returned for single refreshes are reported per result below;
+	// anything else (connection, timeout) fails the whole batch
+	var redisErr redis.Error
+	if err != nil && !errors.As(err, &redisErr) {
+		return nil, err
 	}
 
-	if result.Err() != nil {
-		return "", result.Err()
+	for i, cmd := range cmds {
+		values, err := cmd.StringSlice()
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].SessionStatus, results[i].Status = heartbeatStatus(values)
 	}
 
-	status := result.Val()
-	if status == "" {
-		return domain.StatusUnknown, nil
+	return results, nil
+}
+
+// heartbeatPayload encodes the session activity passed to the heartbeat script
+func heartbeatPayload(client domain.ClientSession, status domain.Status, idle time.Duration) (string, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"device_type": client.DeviceType,
+		"status":      status,
+		"idle_ms":     idle.Milliseconds(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// heartbeatStatus reads the session and effective statuses from the heartbeat script reply
+func heartbeatStatus(values []string) (domain.Status, domain.Status) {
+	if len(values) != 2 {
+		return domain.StatusUnknown, domain.StatusUnknown
 	}
+	return domain.Status(values[0]), domain.Status(values[1])
+}
 
-	return status, nil
+// EndSession removes a session (logout), dropping any status chosen on it
+func (r *RedisUserStatusRepository) EndSession(userID, sessionID string) error {
+	return r.runStatusScript(r.scripts.endSession, userID, sessionID, 0, "", "").Err()
 }
 
-// handleExpiredStatus implements auto-transition logic when keys expire
-func (r *RedisUserStatusRepository) handleExpiredStatus(userID string) (string, error) {
-	// Check if there's a backup status record to determine transition
-	lastStatusKey := fmt.Sprintf("user:last_status:%s", userID)
-	lastStatus, err := r.client.Get(r.ctx, lastStatusKey).Result()
+// GetUserSessions returns all live sessions of a user, pruning expired ones from the index
+func (r *RedisUserStatusRepository) GetUserSessions(userID string) ([]domain.SessionPresence, error) {
+	sessionsKey := domain.GetUserSessionsKey(userID)
 
-	if err == redis.Nil || lastStatus == "" {
-		// No previous status info - user is unknown
-		return domain.StatusUnknown, nil
+	sessionIDs, err := r.client.SMembers(r.ctx, sessionsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessionIDs) == 0 {
+		return nil, nil
 	}
 
-	// Auto-transition based on last known status
-	switch lastStatus {
-	case domain.StatusOnline:
-		// Online expired → Auto-transition to Away
-		r.setStatusWithBackup(userID, domain.StatusAway, domain.AwayTTL)
-		return domain.StatusAway, nil
+	keys := make([]string, len(sessionIDs))
+	for i, sessionID := range sessionIDs {
+		keys[i] = domain.GetUserSessionKey(userID, sessionID)
+	}
 
-	case domain.StatusAway:
-		// Away expired → Auto-transition to Offline
-		r.setStatusWithBackup(userID, domain.StatusOffline, domain.OfflineTTL)
-		return domain.StatusOffline, nil
+	values, err := r.client.MGet(r.ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
 
-	case domain.StatusInvisible, domain.StatusDND:
-		// Invisible/DND expired → Auto-transition to Away (they were active)
-		r.setStatusWithBackup(userID, domain.StatusAway, domain.AwayTTL)
-		return domain.StatusAway, nil
+	sessions := make([]domain.SessionPresence, 0, len(sessionIDs))
+	var expired []interface{}
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			expired = append(expired, sessionIDs[i])
+			continue
+		}
 
-	default:
-		// Offline or other status expired → Unknown
-		return domain.StatusUnknown, nil
+		var session domain.SessionPresence
+		if err := json.Unmarshal([]byte(raw), &session); err != nil {
+			expired = append(expired, sessionIDs[i])
+			continue
+		}
+		sessions = append(sessions, session)
 	}
+
+	if len(expired) > 0 {
+		r.client.SRem(r.ctx, sessionsKey, expired...)
+	}
+
+	return sessions, nil
 }
 
-// GetMultipleUserStatus gets multiple users status using MGET
-func (r *RedisUserStatusRepository) GetMultipleUserStatus(userIDs []string) (map[string]string, error) {
+// GetLastSeen returns the last activity time of a user, or nil if never seen
+func (r *RedisUserStatusRepository) GetLastSeen(userID string) (*time.Time, error) {
+	millis, err := r.client.Get(r.ctx, domain.GetUserLastSeenKey(userID)).Int64()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lastSeen := time.UnixMilli(millis)
+	return &lastSeen, nil
+}
+
+// GetMultipleLastSeen returns last activity times for multiple users using MGET
+func (r *RedisUserStatusRepository) GetMultipleLastSeen(userIDs []string) (map[string]*time.Time, error) {
+	lastSeen := make(map[string]*time.Time)
 	if len(userIDs) == 0 {
-		return make(map[string]string), nil
+		return lastSeen, nil
 	}
 
-	// Build Redis keys
 	keys := make([]string, len(userIDs))
 	for i, userID := range userIDs {
-		keys[i] = domain.GetUserStatusKey(userID)
+		keys[i] = domain.GetUserLastSeenKey(userID)
 	}
 
-	// Execute MGET
-	result := r.client.MGet(r.ctx, keys...)
-	if result.Err() != nil {
-		return nil, result.Err()
+	values, err := r.mget(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		raw, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		millis, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			continue
+		}
+		t := time.UnixMilli(millis)
+		lastSeen[userID] = &t
+	}
+
+	return lastSeen, nil
+}
+
+// runStatusScript runs a status transition script with the shared key/argument layout
+func (r *RedisUserStatusRepository) runStatusScript(script *redis.Script, userID, sessionID string, ttl time.Duration, payload, expectedVersion string) *redis.Cmd {
+	keys, args := r.statusScriptLayout(userID, sessionID, ttl, payload, expectedVersion)
+	return script.Run(r.ctx, r.client, keys, args...)
+}
+
+// statusScriptLayout builds the keys and arguments shared by all status scripts
+func (r *RedisUserStatusRepository) statusScriptLayout(userID, sessionID string, ttl time.Duration, payload, expectedVersion string) ([]string, []interface{}) {
+	now := time.Now()
+	keys := []string{
+		domain.GetUserStatusKey(userID),
+		getLastStatusKey(userID),
+		domain.GetUserSessionsKey(userID),
+		domain.GetUserLastSeenKey(userID),
+		domain.GetUserSessionKey(userID, sessionID),
+		domain.GetStatusHistoryKey(userID),
+		domain.GetUserStatusVersionKey(userID),
+		domain.GetUserDNDKey(userID),
 	}
+	args := []interface{}{
+		domain.GetUserSessionKey(userID, ""),
+		statusBackupTTL.Milliseconds(),
+		sessionID,
+		ttl.Milliseconds(),
+		(ttl + statusBackupTTL).Milliseconds(), // Keep session index as long as status backup
+		now.UnixMilli(),
+		now.Format(time.RFC3339Nano),
+		domain.LastSeenTTL.Milliseconds(),
+		payload,
+		r.history.MaxEntries,
+		r.history.MaxAge.Milliseconds(),
+		expectedVersion,
+		userID,
+		domain.GetPresenceStatsKey(presenceStatsShard(userID), ""),
+		domain.GetDailyActiveKey(now),
+		domain.DailyActiveRetention.Milliseconds(),
+		domain.GetPresenceHeartbeatsKey(presenceStatsShard(userID)),
+	}
+	return keys, args
+}
 
-	// Process results
-	statuses := make(map[string]string)
-	values := result.Val()
+// GetUserStatus gets user status from Redis with auto-transition logic.
+// Live sessions take precedence; the single status key is the fallback once all sessions expired.
+func (r *RedisUserStatusRepository) GetUserStatus(userID string) (domain.Status, error) {
+	resolved, err := r.resolveStatus(userID)
+	return resolved.status, err
+}
+
+// GetUserStatusVersion gets the effective user status together with the presence version it belongs to
+func (r *RedisUserStatusRepository) GetUserStatusVersion(userID string) (domain.Status, int64, error) {
+	resolved, err := r.resolveStatus(userID)
+	return resolved.status, resolved.version, err
+}
+
+// resolvedStatus is the outcome of the get script
+type resolvedStatus struct {
+	status   domain.Status // Effective status
+	previous domain.Status // Backup status it was derived from
+	version  int64         // Presence version
+	cause    string        // Whether live sessions or the expiry transition produced the status
+}
+
+// resolveStatus atomically resolves the effective status (performing any
+// auto-transition) together with the backup status it was derived from
+func (r *RedisUserStatusRepository) resolveStatus(userID string) (resolvedStatus, error) {
+	values, err := r.runStatusScript(r.scripts.get, userID, "", 0, "", "").StringSlice()
+	if err != nil {
+		return resolvedStatus{}, err
+	}
+	if len(values) != 4 {
+		return resolvedStatus{status: domain.StatusUnknown, previous: domain.StatusUnknown}, nil
+	}
+
+	version, err := strconv.ParseInt(values[2], 10, 64)
+	if err != nil {
+		return resolvedStatus{}, err
+	}
+	resolved := resolvedStatus{
+		status:   domain.Status(values[0]),
+		previous: domain.Status(values[1]),
+		version:  version,
+		cause:    values[3],
+	}
+	if values[0] == "" {
+		resolved.status, resolved.previous = domain.StatusUnknown, domain.StatusUnknown
+	}
+	return resolved, nil
+}
+
+// GetMultipleUserStatus gets multiple users status with the same auto-transition
+// logic as GetUserStatus. The status script runs for every user in a single
+// pipeline; if Redis lost the script cache it is loaded and the pipeline retried once.
+func (r *RedisUserStatusRepository) GetMultipleUserStatus(userIDs []string) (map[string]domain.Status, error) {
+	statuses := make(map[string]domain.Status, len(userIDs))
+	if len(userIDs) == 0 {
+		return statuses, nil
+	}
+
+	cmds := make([]*redis.Cmd, len(userIDs))
+	queue := func(pipe redis.Pipeliner) error {
+		for i, userID := range userIDs {
+			keys, args := r.statusScriptLayout(userID, "", 0, "", "")
+			cmds[i] = r.scripts.get.EvalSha(r.ctx, pipe, keys, args...)
+		}
+		return nil
+	}
+
+	_, err := r.client.Pipelined(r.ctx, queue)
+	if redis.HasErrorPrefix(err, "NOSCRIPT") {
+		if err := r.scripts.get.Load(r.ctx, r.client).Err(); err != nil {
+			return nil, err
+		}
+		_, err = r.client.Pipelined(r.ctx, queue)
+	}
+	if err != nil {
+		return nil, err
+	}
 
 	for i, userID := range userIDs {
-		if i < len(values) && values[i] != nil {
-			if status, ok := values[i].(string); ok && status != "" {
-				statuses[userID] = status
-			} else {
-				statuses[userID] = domain.StatusUnknown
-			}
-		} else {
+		values, err := cmds[i].StringSlice()
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 || values[0] == "" {
 			statuses[userID] = domain.StatusUnknown
+			continue
 		}
+		statuses[userID] = domain.Status(values[0])
 	}
 
 	return statuses, nil
 }
 
-// RefreshUserStatusTTL refreshes TTL for user status (heartbeat)
+// RefreshUserStatusTTL refreshes TTL for user status (heartbeat) on the default session
 func (r *RedisUserStatusRepository) RefreshUserStatusTTL(userID string, ttl time.Duration) error {
-	key := domain.GetUserStatusKey(userID)
+	_, _, err := r.RefreshSessionTTL(userID, domain.ClientSession{SessionID: domain.DefaultSessionID}, domain.StatusOnline, 0, ttl)
+	return err
+}
 
-	// Check if key exists and current status
-	status, err := r.GetUserStatus(userID)
-	if err != nil {
-		return err
-	}
+// mgetChunkSize caps the keys of a single MGET so large batches do not stall Redis
+const mgetChunkSize = 500
 
-	// Transition away→online when heartbeat received
-	if status == domain.StatusAway {
-		return r.client.Set(r.ctx, key, domain.StatusOnline, ttl).Err()
+// mget reads keys with MGET in chunks of mgetChunkSize sent in one pipeline.
+// Values are returned in key order, nil for missing keys.
+func (r *RedisUserStatusRepository) mget(keys []string) ([]interface{}, error) {
+	if len(keys) <= mgetChunkSize {
+		return r.client.MGet(r.ctx, keys...).Result()
 	}
 
-	// Refresh TTL if user is online
-	if status == domain.StatusOnline {
-		return r.client.Expire(r.ctx, key, ttl).Err()
+	cmds := make([]*redis.SliceCmd, 0, (len(keys)+mgetChunkSize-1)/mgetChunkSize)
+	_, err := r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for start := 0; start < len(keys); start += mgetChunkSize {
+			end := min(start+mgetChunkSize, len(keys))
+			cmds = append(cmds, pipe.MGet(r.ctx, keys[start:end]...))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	values := make([]interface{}, 0, len(keys))
+	for _, cmd := range cmds {
+		values = append(values, cmd.Val()...)
+	}
+	return values, nil
 }
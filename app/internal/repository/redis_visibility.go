@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"encoding/json"
+
+	"social-app/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SetVisibilityRules stores user's visibility rules (no expiry)
+func (r *RedisUserStatusRepository) SetVisibilityRules(userID string, rules domain.VisibilityRules) error {
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.ctx, domain.GetVisibilityRulesKey(userID), data, 0).Err()
+}
+
+// GetVisibilityRules gets user's visibility rules, returning nil if none are configured
+func (r *RedisUserStatusRepository) GetVisibilityRules(userID string) (*domain.VisibilityRules, error) {
+	raw, err := r.client.Get(r.ctx, domain.GetVisibilityRulesKey(userID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules domain.VisibilityRules
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, nil
+	}
+	return &rules, nil
+}
+
+// GetMultipleVisibilityRules gets visibility rules of multiple users using MGET
+func (r *RedisUserStatusRepository) GetMultipleVisibilityRules(userIDs []string) (map[string]*domain.VisibilityRules, error) {
+	rulesByUser := make(map[string]*domain.VisibilityRules)
+	if len(userIDs) == 0 {
+		return rulesByUser, nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = domain.GetVisibilityRulesKey(userID)
+	}
+
+	values, err := r.mget(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		raw, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		var rules domain.VisibilityRules
+		if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+			continue
+		}
+		rulesByUser[userID] = &rules
+	}
+
+	return rulesByUser, nil
+}
+
+// DeleteVisibilityRules removes user's visibility rules
+func (r *RedisUserStatusRepository) DeleteVisibilityRules(userID string) error {
+	return r.client.Del(r.ctx, domain.GetVisibilityRulesKey(userID)).Err()
+}
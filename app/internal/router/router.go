@@ -1,13 +1,27 @@
 package router
 
 import (
+	"crypto/subtle"
+	"net/http"
 	"social-app/internal/handler"
 	"social-app/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(userStatusService *services.UserStatusService) *gin.Engine {
+// InternalTokenHeader carries the shared secret of internal service-to-service calls
+const InternalTokenHeader = "X-Internal-Token"
+
+// Services are the application services the API routes to
+type Services struct {
+	UserStatus    *services.UserStatusService
+	Rooms         *services.RoomService
+	Typing        *services.TypingService
+	PresenceStats *services.PresenceStatsService
+	Contacts      *services.ContactsService
+}
+
+func SetupRouter(svc Services, internalToken string) *gin.Engine {
 	// Create Gin router
 	r := gin.Default()
 
@@ -17,7 +31,11 @@ func SetupRouter(userStatusService *services.UserStatusService) *gin.Engine {
 	r.Use(CORSMiddleware())
 
 	// Initialize handlers
-	userStatusHandler := handler.NewUserStatusHandler(userStatusService)
+	userStatusHandler := handler.NewUserStatusHandler(svc.UserStatus)
+	roomHandler := handler.NewRoomHandler(svc.Rooms)
+	typingHandler := handler.NewTypingHandler(svc.Typing)
+	presenceStatsHandler := handler.NewPresenceStatsHandler(svc.PresenceStats)
+	contactsHandler := handler.NewContactsHandler(svc.Contacts)
 
 	// API versioning
 	v1 := r.Group("/api/v1")
@@ -26,18 +44,72 @@ func SetupRouter(userStatusService *services.UserStatusService) *gin.Engine {
 		users := v1.Group("/users")
 		{
 			// Individual user status
-			users.POST("/:id/status", userStatusHandler.SetUserStatus)             // Set status (online/away/offline/invisible/dnd)
-			users.GET("/:id/status", userStatusHandler.GetUserStatus)              // Get user status (own status)
-			users.GET("/:id/status/public", userStatusHandler.GetPublicUserStatus) // Get public status (visible to others)
-			users.POST("/:id/heartbeat", userStatusHandler.SendHeartbeat)          // Send heartbeat
-			users.PUT("/:id/status/away", userStatusHandler.SetUserAway)           // Set user away
-			users.PUT("/:id/status/offline", userStatusHandler.SetUserOffline)     // Set user offline
-			users.PUT("/:id/status/invisible", userStatusHandler.SetUserInvisible) // Set user invisible
-			users.PUT("/:id/status/dnd", userStatusHandler.SetUserDND)             // Set user do not disturb
+			users.POST("/:id/status", userStatusHandler.SetUserStatus)                                        // Set status (online/away/offline/invisible/dnd)
+			users.GET("/:id/status", userStatusHandler.GetUserStatus)                                         // Get user status (own status)
+			users.GET("/:id/status/public", userStatusHandler.GetPublicUserStatus)                            // Get public status (visible to others)
+			users.GET("/:id/status/history", InternalOnly(internalToken), userStatusHandler.GetStatusHistory) // Get raw status change history (internal)
+			users.POST("/:id/heartbeat", userStatusHandler.SendHeartbeat)                                     // Send heartbeat
+			users.DELETE("/:id/sessions/:session_id", userStatusHandler.EndSession)                           // Log a session out
+			users.PUT("/:id/status/away", userStatusHandler.SetUserAway)                                      // Set user away
+			users.PUT("/:id/status/offline", userStatusHandler.SetUserOffline)                                // Set user offline
+			users.PUT("/:id/status/invisible", userStatusHandler.SetUserInvisible)                            // Set user invisible
+			users.PUT("/:id/status/dnd", userStatusHandler.SetUserDND)                                        // Set user do not disturb (optionally until a time)
+			users.DELETE("/:id/status/dnd", userStatusHandler.ClearUserDND)                                   // End do not disturb
+			users.PUT("/:id/status/custom", userStatusHandler.SetCustomStatus)                                // Set custom status text/emoji
+			users.DELETE("/:id/status/custom", userStatusHandler.ClearCustomStatus)                           // Clear custom status
+			users.GET("/:id/last-seen", userStatusHandler.GetLastSeen)                                        // Get last seen time
+
+			// Recurring quiet hours
+			users.GET("/:id/quiet-hours", userStatusHandler.GetQuietHours)       // Get quiet hours schedule
+			users.PUT("/:id/quiet-hours", userStatusHandler.SetQuietHours)       // Create or replace quiet hours schedule
+			users.DELETE("/:id/quiet-hours", userStatusHandler.DeleteQuietHours) // Remove quiet hours schedule
+
+			// Viewer-specific visibility rules
+			users.GET("/:id/visibility", userStatusHandler.GetVisibilityRules)       // Get visibility rules
+			users.PUT("/:id/visibility", userStatusHandler.SetVisibilityRules)       // Create or replace visibility rules
+			users.DELETE("/:id/visibility", userStatusHandler.DeleteVisibilityRules) // Remove visibility rules
+
+			// Privacy settings and contacts
+			users.GET("/:id/settings/privacy", userStatusHandler.GetPrivacySettings)             // Get privacy settings (last seen audience)
+			users.PUT("/:id/settings/privacy", userStatusHandler.SetPrivacySettings)             // Set privacy settings
+			users.GET("/:id/contacts", InternalOnly(internalToken), contactsHandler.GetContacts) // Get contact list (internal)
+			users.POST("/:id/contacts", contactsHandler.AddContacts)                             // Add contacts
+			users.DELETE("/:id/contacts/:contact_id", contactsHandler.RemoveContact)             // Remove a contact
+			users.GET("/:id/contacts/online", contactsHandler.GetOnlineContacts)                 // Get online contacts, most recent first
 
 			// Bulk operations
-			users.GET("/status", userStatusHandler.GetMultipleUserStatus) // Get multiple users status
+			users.GET("/status/public", userStatusHandler.GetMultiplePublicUserStatus)                    // Get multiple users status as seen by others
+			users.POST("/status/public", userStatusHandler.QueryMultiplePublicUserStatus)                 // Same, with the user IDs in a JSON body
+			users.GET("/status", InternalOnly(internalToken), userStatusHandler.GetMultipleUserStatus)    // Get multiple users raw status (internal)
+			users.POST("/status", InternalOnly(internalToken), userStatusHandler.QueryMultipleUserStatus) // Same, with the user IDs in a JSON body (internal)
+			users.POST("/heartbeats", InternalOnly(internalToken), userStatusHandler.SendBulkHeartbeat)   // Heartbeat many sessions at once (internal, gateways)
+		}
+
+		// Room presence (who is online in a channel or group chat)
+		rooms := v1.Group("/rooms")
+		{
+			rooms.PUT("/:room_id/members/:user_id", roomHandler.JoinRoom)     // Join a room
+			rooms.DELETE("/:room_id/members/:user_id", roomHandler.LeaveRoom) // Leave a room
+			rooms.GET("/:room_id/members", roomHandler.GetRoomPresence)       // Get members present with statuses
+			rooms.GET("/:room_id/count", roomHandler.GetRoomCount)            // Get present member counts
+		}
+
+		// Typing indicators (also published on events:typing:{conversation_id})
+		conversations := v1.Group("/conversations")
+		{
+			conversations.PUT("/:conversation_id/typing/:user_id", typingHandler.StartTyping)   // Start typing
+			conversations.DELETE("/:conversation_id/typing/:user_id", typingHandler.StopTyping) // Stop typing
+			conversations.GET("/:conversation_id/typing", typingHandler.GetTypingUsers)         // Get who is typing
+		}
+
+		// Internal admin endpoints
+		admin := v1.Group("/admin", InternalOnly(internalToken))
+		{
+			admin.GET("/presence/stats", presenceStatsHandler.GetPresenceStats) // Get online counts and daily actives
 		}
+
+		// Presence policy reported to clients
+		v1.GET("/presence/policy", userStatusHandler.GetPresencePolicy) // Get heartbeat interval and status TTLs
 	}
 
 	// Health check endpoint
@@ -56,15 +128,54 @@ func SetupRouter(userStatusService *services.UserStatusService) *gin.Engine {
 			"endpoints": map[string]interface{}{
 				"health": "GET /health",
 				"user_status": map[string]string{
-					"set_status":        "POST /api/v1/users/:id/status",
-					"get_status":        "GET /api/v1/users/:id/status",
-					"get_public_status": "GET /api/v1/users/:id/status/public",
-					"send_heartbeat":    "POST /api/v1/users/:id/heartbeat",
-					"set_away":          "PUT /api/v1/users/:id/status/away",
-					"set_offline":       "PUT /api/v1/users/:id/status/offline",
-					"set_invisible":     "PUT /api/v1/users/:id/status/invisible",
-					"set_dnd":           "PUT /api/v1/users/:id/status/dnd",
-					"get_multiple":      "GET /api/v1/users/status?user_ids=123,456",
+					"set_status":            "POST /api/v1/users/:id/status",
+					"get_status":            "GET /api/v1/users/:id/status",
+					"get_public_status":     "GET /api/v1/users/:id/status/public",
+					"get_status_history":    "GET /api/v1/users/:id/status/history?limit=50&cursor= (internal, X-Internal-Token)",
+					"send_heartbeat":        "POST /api/v1/users/:id/heartbeat",
+					"end_session":           "DELETE /api/v1/users/:id/sessions/:session_id",
+					"set_away":              "PUT /api/v1/users/:id/status/away",
+					"set_offline":           "PUT /api/v1/users/:id/status/offline",
+					"set_invisible":         "PUT /api/v1/users/:id/status/invisible",
+					"set_dnd":               "PUT /api/v1/users/:id/status/dnd",
+					"clear_dnd":             "DELETE /api/v1/users/:id/status/dnd",
+					"set_custom_status":     "PUT /api/v1/users/:id/status/custom",
+					"clear_custom_status":   "DELETE /api/v1/users/:id/status/custom",
+					"get_last_seen":         "GET /api/v1/users/:id/last-seen",
+					"get_quiet_hours":       "GET /api/v1/users/:id/quiet-hours",
+					"set_quiet_hours":       "PUT /api/v1/users/:id/quiet-hours",
+					"delete_quiet_hours":    "DELETE /api/v1/users/:id/quiet-hours",
+					"get_visibility":        "GET /api/v1/users/:id/visibility",
+					"set_visibility":        "PUT /api/v1/users/:id/visibility",
+					"delete_visibility":     "DELETE /api/v1/users/:id/visibility",
+					"get_privacy":           "GET /api/v1/users/:id/settings/privacy",
+					"set_privacy":           "PUT /api/v1/users/:id/settings/privacy",
+					"get_contacts":          "GET /api/v1/users/:id/contacts (internal, X-Internal-Token)",
+					"add_contacts":          "POST /api/v1/users/:id/contacts",
+					"remove_contact":        "DELETE /api/v1/users/:id/contacts/:contact_id",
+					"get_online_contacts":   "GET /api/v1/users/:id/contacts/online?limit=50&cursor=",
+					"get_multiple_public":   "GET /api/v1/users/status/public?user_ids=123,456",
+					"get_multiple":          "GET /api/v1/users/status?user_ids=123,456 (internal, X-Internal-Token)",
+					"query_multiple_public": "POST /api/v1/users/status/public {\"user_ids\": [...]}",
+					"query_multiple":        "POST /api/v1/users/status {\"user_ids\": [...]} (internal, X-Internal-Token)",
+					"bulk_heartbeat":        "POST /api/v1/users/heartbeats {\"heartbeats\": [{\"user_id\": \"123\", \"session_id\": \"...\"}]} (internal, X-Internal-Token)",
+				},
+				"rooms": map[string]string{
+					"join":        "PUT /api/v1/rooms/:room_id/members/:user_id",
+					"leave":       "DELETE /api/v1/rooms/:room_id/members/:user_id",
+					"get_members": "GET /api/v1/rooms/:room_id/members",
+					"get_count":   "GET /api/v1/rooms/:room_id/count",
+				},
+				"typing": map[string]string{
+					"start": "PUT /api/v1/conversations/:conversation_id/typing/:user_id",
+					"stop":  "DELETE /api/v1/conversations/:conversation_id/typing/:user_id",
+					"get":   "GET /api/v1/conversations/:conversation_id/typing",
+				},
+				"presence": map[string]string{
+					"get_policy": "GET /api/v1/presence/policy?device_type=mobile",
+				},
+				"admin": map[string]string{
+					"get_presence_stats": "GET /api/v1/admin/presence/stats?date=2026-01-02 (internal, X-Internal-Token)",
 				},
 			},
 		})
@@ -78,7 +189,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Session-ID, X-Device-Type, X-Viewer-ID, If-Match")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -89,3 +201,20 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// InternalOnly restricts a route to internal callers presenting the shared token.
+// Without a configured token the route is closed.
+func InternalOnly(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader(InternalTokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "endpoint is restricted to internal callers",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
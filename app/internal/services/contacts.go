@@ -0,0 +1,220 @@
+package services
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"social-app/internal/domain"
+)
+
+// MaxContactsPerRequest limits how many contacts can be added at once
+const MaxContactsPerRequest = 1000
+
+// ContactsService manages contact lists and lists the contacts who are online.
+// Contacts are shown as the user sees them, through the UserStatusService.
+type ContactsService struct {
+	repo     domain.ContactsRepository
+	statuses *UserStatusService
+}
+
+// NewContactsService creates a new ContactsService with the given contacts repository and user status service
+func NewContactsService(repo domain.ContactsRepository, statuses *UserStatusService) *ContactsService {
+	return &ContactsService{
+		repo:     repo,
+		statuses: statuses,
+	}
+}
+
+// AddContacts adds users to user's contact list
+func (s *ContactsService) AddContacts(userID string, contactIDs []string) error {
+	if err := s.statuses.validateUserID(&userID); err != nil {
+		return err
+	}
+	if len(contactIDs) == 0 {
+		return invalidf("contact IDs cannot be empty")
+	}
+	if len(contactIDs) > MaxContactsPerRequest {
+		return invalidf("too many contacts (max %d per request)", MaxContactsPerRequest)
+	}
+
+	normalized := make([]string, len(contactIDs))
+	for i, contactID := range contactIDs {
+		if err := s.statuses.validateUserID(&contactID); err != nil {
+			return err
+		}
+		if contactID == userID {
+			return invalidf("user cannot be their own contact")
+		}
+		normalized[i] = contactID
+	}
+
+	return s.repo.AddContacts(userID, normalized)
+}
+
+// RemoveContact removes a user from user's contact list
+func (s *ContactsService) RemoveContact(userID, contactID string) error {
+	if err := s.statuses.validateUserID(&userID); err != nil {
+		return err
+	}
+	if err := s.statuses.validateUserID(&contactID); err != nil {
+		return err
+	}
+	return s.repo.RemoveContact(userID, contactID)
+}
+
+// GetContacts returns user's contact list
+func (s *ContactsService) GetContacts(userID string) ([]string, error) {
+	if err := s.statuses.validateUserID(&userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetContacts(userID)
+}
+
+// Online contacts page sizes
+const (
+	DefaultOnlineContactsLimit = 50
+	MaxOnlineContactsLimit     = 200
+)
+
+// GetOnlineContacts returns a page of user's contacts who are currently present
+// (online, away or DND as the user sees them), most recently active first, and the
+// cursor for the next page, empty when there are no more contacts
+func (s *ContactsService) GetOnlineContacts(userID string, query domain.OnlineContactsQuery) ([]*domain.UserStatus, string, error) {
+	if err := s.statuses.validateUserID(&userID); err != nil {
+		return nil, "", err
+	}
+
+	if query.Limit == 0 {
+		query.Limit = DefaultOnlineContactsLimit
+	}
+	if query.Limit < 0 || query.Limit > MaxOnlineContactsLimit {
+		return nil, "", invalidf("limit must be between 1 and 200")
+	}
+
+	// The first page snapshots the candidates with their heartbeat scores; later pages
+	// continue in that snapshot, so contacts who heartbeat meanwhile keep their place
+	var cursor contactCursor
+	var candidates []domain.ContactActivity
+	if query.Cursor == "" {
+		now := time.Now()
+		cursor.snapshot = strconv.FormatInt(now.UnixMilli(), 10)
+		active, err := s.repo.GetActiveContacts(userID, now.Add(-s.statuses.policy.LiveWindow()))
+		if err != nil {
+			return nil, "", err
+		}
+		candidates = active
+	} else {
+		parsed, err := parseContactCursor(query.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		cursor = parsed
+		snapshot, err := s.repo.GetContactsSnapshot(userID, cursor.snapshot)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(snapshot) == 0 {
+			return nil, "", invalidf("cursor expired, start again from the first page")
+		}
+		candidates = snapshot
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return contactBefore(candidates[i], candidates[j])
+	})
+
+	remaining := candidates
+	if query.Cursor != "" {
+		start := sort.Search(len(candidates), func(i int) bool {
+			return contactBefore(cursor.after, candidates[i])
+		})
+		remaining = candidates[start:]
+	}
+
+	// Collect one extra present contact to know whether another page exists
+	limit := int(query.Limit)
+	var page []*domain.UserStatus
+	var pageActivity []domain.ContactActivity
+	for start := 0; start < len(remaining) && len(page) <= limit; start += limit + 1 {
+		chunk := remaining[start:min(start+limit+1, len(remaining))]
+
+		ids := make([]string, len(chunk))
+		for i, candidate := range chunk {
+			ids[i] = candidate.UserID
+		}
+		statuses, err := s.statuses.loadStatuses(ids)
+		if err != nil {
+			return nil, "", err
+		}
+		public, err := s.statuses.publicStatuses(statuses, userID)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, candidate := range chunk {
+			status := public[candidate.UserID]
+			if status == nil || !status.Status.IsPresent() {
+				continue
+			}
+			page = append(page, status)
+			pageActivity = append(pageActivity, candidate)
+			if len(page) > limit {
+				break
+			}
+		}
+	}
+
+	nextCursor := ""
+	if len(page) > limit {
+		if query.Cursor == "" {
+			if err := s.repo.SaveContactsSnapshot(userID, cursor.snapshot, candidates); err != nil {
+				return nil, "", err
+			}
+		}
+		page = page[:limit]
+		cursor.after = pageActivity[limit-1]
+		nextCursor = formatContactCursor(cursor)
+	}
+
+	return page, nextCursor, nil
+}
+
+// contactBefore orders contacts by most recent heartbeat, then by ID
+func contactBefore(a, b domain.ContactActivity) bool {
+	if !a.LastHeartbeat.Equal(b.LastHeartbeat) {
+		return a.LastHeartbeat.After(b.LastHeartbeat)
+	}
+	return a.UserID < b.UserID
+}
+
+// contactCursor is a position in the contacts snapshot of a paginated listing
+type contactCursor struct {
+	snapshot string                 // Snapshot the listing continues in (unix ms of the first page)
+	after    domain.ContactActivity // Last contact returned, with its snapshot score
+}
+
+// formatContactCursor renders a position as "{snapshot}:{unix ms}:{user ID}"
+func formatContactCursor(cursor contactCursor) string {
+	return cursor.snapshot + ":" + strconv.FormatInt(cursor.after.LastHeartbeat.UnixMilli(), 10) + ":" + cursor.after.UserID
+}
+
+// parseContactCursor parses a cursor produced by formatContactCursor
+func parseContactCursor(value string) (contactCursor, error) {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return contactCursor{}, invalidf("invalid cursor")
+	}
+	if _, err := strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return contactCursor{}, invalidf("invalid cursor")
+	}
+	ms, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return contactCursor{}, invalidf("invalid cursor")
+	}
+	return contactCursor{
+		snapshot: parts[0],
+		after:    domain.ContactActivity{UserID: parts[2], LastHeartbeat: time.UnixMilli(ms)},
+	}, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"social-app/internal/domain"
+)
+
+// Custom status limits
+const (
+	MaxCustomStatusTextLength  = 100
+	MaxCustomStatusEmojiLength = 16 // Runes; covers ZWJ sequences and skin tones
+	MaxCustomStatusClearAfter  = 30 * 24 * time.Hour
+)
+
+// SetCustomStatus sets custom status text/emoji, cleared automatically after clearAfter (0 = never)
+func (s *UserStatusService) SetCustomStatus(userID string, custom domain.CustomStatus, clearAfter time.Duration) (*domain.CustomStatus, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return nil, err
+	}
+
+	custom.Text = strings.TrimSpace(custom.Text)
+	custom.Emoji = strings.TrimSpace(custom.Emoji)
+	if err := validateCustomStatus(custom, clearAfter); err != nil {
+		return nil, err
+	}
+
+	custom.ExpiresAt = nil
+	if clearAfter > 0 {
+		expiresAt := time.Now().Add(clearAfter)
+		custom.ExpiresAt = &expiresAt
+	}
+
+	if err := s.repo.SetCustomStatus(userID, custom, clearAfter); err != nil {
+		return nil, err
+	}
+	return &custom, nil
+}
+
+// ClearCustomStatus removes custom status
+func (s *UserStatusService) ClearCustomStatus(userID string) error {
+	if err := s.validateUserID(&userID); err != nil {
+		return err
+	}
+	return s.repo.ClearCustomStatus(userID)
+}
+
+// validateCustomStatus validates custom status length and content
+func validateCustomStatus(custom domain.CustomStatus, clearAfter time.Duration) error {
+	if custom.Text == "" && custom.Emoji == "" {
+		return invalidf("custom status must have text or emoji")
+	}
+
+	if utf8.RuneCountInString(custom.Text) > MaxCustomStatusTextLength {
+		return invalidf("custom status text too long (max 100 characters)")
+	}
+
+	for _, r := range custom.Text {
+		if unicode.IsControl(r) {
+			return invalidf("custom status text must not contain control characters or line breaks")
+		}
+	}
+
+	if utf8.RuneCountInString(custom.Emoji) > MaxCustomStatusEmojiLength {
+		return invalidf("custom status emoji too long")
+	}
+
+	if custom.Emoji != "" && !isSingleEmoji(custom.Emoji) {
+		return invalidf("custom status emoji must be a single emoji")
+	}
+
+	if clearAfter < 0 || clearAfter > MaxCustomStatusClearAfter {
+		return invalidf("custom status clear_after must be between 0 and 30 days")
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"time"
+
+	"social-app/internal/domain"
+)
+
+// MaxDNDDuration limits how far ahead a do-not-disturb period may end
+const MaxDNDDuration = 30 * 24 * time.Hour
+
+// SetUserDND starts do-not-disturb until the given time (nil = until cleared).
+// It survives heartbeats and disconnects and ends exactly at the deadline,
+// after which the automatic presence status shows again.
+func (s *UserStatusService) SetUserDND(userID string, until *time.Time) (*domain.DNDState, error) {
+	dnd, _, err := s.setUserDND(userID, until, domain.AnyVersion)
+	return dnd, err
+}
+
+// setUserDND starts do-not-disturb if the presence version still matches and returns the new version
+func (s *UserStatusService) setUserDND(userID string, until *time.Time, version int64) (*domain.DNDState, int64, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	if until != nil {
+		if !until.After(now) {
+			return nil, 0, invalidf("DND end time must be in the future")
+		}
+		if until.Sub(now) > MaxDNDDuration {
+			return nil, 0, invalidf("DND end time too far ahead (max 30 days)")
+		}
+	}
+
+	dnd := domain.DNDState{
+		Since: now,
+		Until: until,
+	}
+	newVersion, err := s.repo.CompareAndSetDND(userID, dnd, version)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &dnd, newVersion, nil
+}
+
+// ClearUserDND ends do-not-disturb before its deadline
+func (s *UserStatusService) ClearUserDND(userID string) error {
+	if err := s.validateUserID(&userID); err != nil {
+		return err
+	}
+	return s.repo.ClearDND(userID)
+}
+
+// applyDND overlays an active DND period on the automatic presence status.
+// Only statuses that show DND (online and away) are overlaid: invisible still wins so
+// DND cannot reveal an invisible user, and offline or unknown users stay offline.
+func applyDND(status *domain.UserStatus, dnd *domain.DNDState) {
+	if !dnd.Active(time.Now()) {
+		return
+	}
+	if !status.Status.ShowsDND() {
+		return
+	}
+	status.Status = domain.StatusDND
+	status.DNDUntil = dnd.Until
+}
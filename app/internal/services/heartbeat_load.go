@@ -0,0 +1,59 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// heartbeatLoadWindow is the window over which this instance measures its heartbeat rate
+const heartbeatLoadWindow = 10 * time.Second
+
+// heartbeatLoad measures how many heartbeats per second this instance handles.
+// The rate is taken from the last complete window, so it changes at most once per window.
+type heartbeatLoad struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int64
+	rate        float64
+}
+
+// record counts a heartbeat and returns the current rate
+func (l *heartbeatLoad) record(now time.Time) float64 {
+	return l.recordN(now, 1)
+}
+
+// recordN counts n heartbeats (e.g. one bulk heartbeat) and returns the current rate
+func (l *heartbeatLoad) recordN(now time.Time, n int) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.roll(now)
+	l.count += int64(n)
+	return l.rate
+}
+
+// current returns the current rate without counting a heartbeat
+func (l *heartbeatLoad) current(now time.Time) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.roll(now)
+	return l.rate
+}
+
+// roll closes the current window once it has passed. A gap of more than one
+// window means the instance was idle, so the rate drops to zero.
+func (l *heartbeatLoad) roll(now time.Time) {
+	elapsed := now.Sub(l.windowStart)
+	if elapsed < heartbeatLoadWindow {
+		return
+	}
+
+	if elapsed < 2*heartbeatLoadWindow {
+		l.rate = float64(l.count) / heartbeatLoadWindow.Seconds()
+	} else {
+		l.rate = 0
+	}
+	l.windowStart = now
+	l.count = 0
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"time"
+
+	"social-app/internal/domain"
+)
+
+// PresenceStatsService reports live presence counts and daily active users
+type PresenceStatsService struct {
+	repo domain.PresenceStatsRepository
+}
+
+// NewPresenceStatsService creates a new PresenceStatsService with the given repository
+func NewPresenceStatsService(repo domain.PresenceStatsRepository) *PresenceStatsService {
+	return &PresenceStatsService{
+		repo: repo,
+	}
+}
+
+// GetPresenceStats returns how many users are present per status right now, and
+// the approximate number of unique users active on the given UTC day (zero = today)
+func (s *PresenceStatsService) GetPresenceStats(day time.Time) (*domain.PresenceStats, error) {
+	now := time.Now().UTC()
+	if day.IsZero() {
+		day = now
+	}
+	day = day.UTC()
+	if day.After(now) {
+		return nil, invalidf("date cannot be in the future")
+	}
+	if now.Sub(day) > domain.DailyActiveRetention {
+		return nil, invalidf("date is older than the daily active retention")
+	}
+
+	counts, err := s.repo.GetStatusCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	dailyActive, err := s.repo.CountDailyActive(day)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &domain.PresenceStats{
+		Counts:      counts,
+		Date:        day.Format(time.DateOnly),
+		DailyActive: dailyActive,
+		Timestamp:   now,
+	}
+	for status, count := range counts {
+		if status.Public().IsPresent() {
+			stats.Online += count
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"time"
+
+	"social-app/internal/domain"
+)
+
+// SetPrivacySettings creates or replaces user's presence privacy settings
+func (s *UserStatusService) SetPrivacySettings(userID string, settings domain.PrivacySettings) (*domain.PrivacySettings, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return nil, err
+	}
+	if settings.LastSeen == "" {
+		settings.LastSeen = domain.AudienceEveryone
+	}
+	if !settings.LastSeen.IsValid() {
+		return nil, invalidf("invalid last seen audience: %s (must be everyone, contacts, or nobody)", settings.LastSeen)
+	}
+
+	settings.UserID = userID
+	settings.UpdatedAt = time.Now()
+	if err := s.repo.SetPrivacySettings(userID, settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// GetPrivacySettings returns user's presence privacy settings, with defaults if none are configured
+func (s *UserStatusService) GetPrivacySettings(userID string) (*domain.PrivacySettings, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return nil, err
+	}
+
+	settings, err := s.repo.GetPrivacySettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = &domain.PrivacySettings{UserID: userID}
+	}
+	settings.LastSeen = settings.LastSeenAudience()
+	return settings, nil
+}
+
+// lastSeenVisible reports whether the viewer may see user's last seen. The setting
+// is reciprocal: the viewer must be in the user's audience and the user in the
+// viewer's, so hiding your own last seen hides everyone else's from you.
+// Anonymous viewers only see last seen shared with everyone. The viewer header is
+// not authenticated, so a viewer claiming to be the user gets no special treatment.
+func (s *UserStatusService) lastSeenVisible(userID, viewerID string) (bool, error) {
+	owner, err := s.repo.GetPrivacySettings(userID)
+	if err != nil {
+		return false, err
+	}
+	audience := owner.LastSeenAudience()
+	if viewerID == "" || audience == domain.AudienceNobody {
+		return audience == domain.AudienceEveryone, nil
+	}
+
+	viewer, err := s.repo.GetPrivacySettings(viewerID)
+	if err != nil {
+		return false, err
+	}
+	viewerAudience := viewer.LastSeenAudience()
+
+	var viewerIsContact, userIsContact bool
+	if audience == domain.AudienceContacts {
+		found, err := s.contacts.HasContacts(userID, []string{viewerID})
+		if err != nil {
+			return false, err
+		}
+		viewerIsContact = found[viewerID]
+	}
+	if viewerAudience == domain.AudienceContacts {
+		found, err := s.contacts.HasContacts(viewerID, []string{userID})
+		if err != nil {
+			return false, err
+		}
+		userIsContact = found[userID]
+	}
+
+	return audience.Allows(viewerIsContact) && viewerAudience.Allows(userIsContact), nil
+}
+
+// lastSeenVisibleMultiple is lastSeenVisible for many users with one round trip per lookup
+func (s *UserStatusService) lastSeenVisibleMultiple(userIDs []string, viewerID string) (map[string]bool, error) {
+	visible := make(map[string]bool, len(userIDs))
+
+	owners, err := s.repo.GetMultiplePrivacySettings(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if viewerID == "" {
+		for _, userID := range userIDs {
+			visible[userID] = owners[userID].LastSeenAudience() == domain.AudienceEveryone
+		}
+		return visible, nil
+	}
+
+	viewer, err := s.repo.GetPrivacySettings(viewerID)
+	if err != nil {
+		return nil, err
+	}
+	viewerAudience := viewer.LastSeenAudience()
+
+	var contactsOnly []string
+	for _, userID := range userIDs {
+		if owners[userID].LastSeenAudience() == domain.AudienceContacts {
+			contactsOnly = append(contactsOnly, userID)
+		}
+	}
+	viewerIsContact, err := s.contacts.GetMultipleHasContact(contactsOnly, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	userIsContact := map[string]bool{}
+	if viewerAudience == domain.AudienceContacts {
+		userIsContact, err = s.contacts.HasContacts(viewerID, userIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, userID := range userIDs {
+		visible[userID] = owners[userID].LastSeenAudience().Allows(viewerIsContact[userID]) && viewerAudience.Allows(userIsContact[userID])
+	}
+	return visible, nil
+}
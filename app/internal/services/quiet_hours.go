@@ -0,0 +1,105 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"social-app/internal/domain"
+)
+
+// MaxQuietHoursWindows limits the number of windows in a schedule
+const MaxQuietHoursWindows = 14
+
+// SetQuietHours creates or replaces user's recurring quiet hours schedule
+func (s *UserStatusService) SetQuietHours(userID string, schedule domain.QuietHoursSchedule) (*domain.QuietHoursSchedule, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return nil, err
+	}
+	if err := validateQuietHours(&schedule); err != nil {
+		return nil, err
+	}
+
+	schedule.UserID = userID
+	schedule.UpdatedAt = time.Now()
+	if err := s.repo.SetQuietHours(userID, schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetQuietHours returns user's quiet hours schedule, or nil if none is configured
+func (s *UserStatusService) GetQuietHours(userID string) (*domain.QuietHoursSchedule, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetQuietHours(userID)
+}
+
+// DeleteQuietHours removes user's quiet hours schedule
+func (s *UserStatusService) DeleteQuietHours(userID string) error {
+	if err := s.validateUserID(&userID); err != nil {
+		return err
+	}
+	return s.repo.DeleteQuietHours(userID)
+}
+
+// applyQuietHours reports DND while a quiet hours window is running. Unlike an
+// explicit DND it merges with live presence: only statuses that show DND are affected.
+func applyQuietHours(status *domain.UserStatus, schedule *domain.QuietHoursSchedule) {
+	if !status.Status.ShowsDND() {
+		return
+	}
+
+	end := schedule.ActiveUntil(time.Now())
+	if end == nil {
+		return
+	}
+	status.Status = domain.StatusDND
+	status.DNDUntil = end
+}
+
+// validateQuietHours validates and normalizes a quiet hours schedule
+func validateQuietHours(schedule *domain.QuietHoursSchedule) error {
+	if schedule.TimeZone == "" {
+		return invalidf("time zone is required")
+	}
+	if _, err := domain.LoadLocation(schedule.TimeZone); err != nil {
+		return invalidf("invalid time zone: %s", schedule.TimeZone)
+	}
+
+	if len(schedule.Windows) == 0 {
+		return invalidf("quiet hours must have at least one window")
+	}
+	if len(schedule.Windows) > MaxQuietHoursWindows {
+		return invalidf("too many quiet hours windows (max 14)")
+	}
+
+	for i := range schedule.Windows {
+		window := &schedule.Windows[i]
+
+		if len(window.Days) == 0 {
+			return invalidf("quiet hours window must have at least one day")
+		}
+		for j, day := range window.Days {
+			day = strings.ToLower(strings.TrimSpace(day))
+			if _, ok := domain.QuietHoursDays[day]; !ok {
+				return invalidf("invalid day: %s (must be mon, tue, wed, thu, fri, sat, or sun)", window.Days[j])
+			}
+			window.Days[j] = day
+		}
+
+		start, err := domain.ParseClock(window.Start)
+		if err != nil {
+			return domain.InvalidInput(err)
+		}
+		end, err := domain.ParseClock(window.End)
+		if err != nil {
+			return domain.InvalidInput(err)
+		}
+		if start == end {
+			return invalidf("quiet hours window start and end must differ")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"social-app/internal/domain"
+)
+
+// Limits for room presence
+const (
+	MaxRoomIDLength = 100  // Also used for conversation IDs
+	MaxRoomMembers  = 5000 // Members present at the same time
+)
+
+// spaceIDPattern keeps room and conversation IDs safe to embed in Redis keys
+var spaceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// RoomService tracks who is present in rooms. Members ride on the user status
+// lifecycle, so statuses are read and masked through the UserStatusService.
+type RoomService struct {
+	repo     domain.RoomRepository
+	statuses *UserStatusService
+}
+
+// NewRoomService creates a new RoomService with the given room repository and user status service
+func NewRoomService(repo domain.RoomRepository, statuses *UserStatusService) *RoomService {
+	return &RoomService{
+		repo:     repo,
+		statuses: statuses,
+	}
+}
+
+// JoinRoom marks user as present in a room. Offline users and users without presence
+// cannot join; the others stay in the room while their heartbeats keep them present.
+func (s *RoomService) JoinRoom(roomID, userID string) error {
+	if err := validateRoomID(roomID); err != nil {
+		return err
+	}
+	if err := s.statuses.validateUserID(&userID); err != nil {
+		return err
+	}
+
+	if err := s.requirePresent(userID); err != nil {
+		return err
+	}
+
+	err := s.repo.JoinRoom(roomID, userID, MaxRoomMembers, s.statuses.policy.LiveWindow())
+	if errors.Is(err, domain.ErrRoomFull) {
+		return fmt.Errorf("%w (max %d present members)", err, MaxRoomMembers)
+	}
+	return err
+}
+
+// LeaveRoom removes user from a room
+func (s *RoomService) LeaveRoom(roomID, userID string) error {
+	if err := validateRoomID(roomID); err != nil {
+		return err
+	}
+	if err := s.statuses.validateUserID(&userID); err != nil {
+		return err
+	}
+	return s.repo.LeaveRoom(roomID, userID)
+}
+
+// GetRoomPresence returns the members present in a room as visible to the viewer,
+// most available first, with counts per status. Members whose status went offline
+// or expired are pruned from the room on the way.
+func (s *RoomService) GetRoomPresence(roomID, viewerID string) (*domain.RoomPresence, error) {
+	if err := validateRoomID(roomID); err != nil {
+		return nil, err
+	}
+	if err := s.statuses.validateViewerID(&viewerID); err != nil {
+		return nil, err
+	}
+
+	presence := &domain.RoomPresence{
+		RoomID:  roomID,
+		Members: []*domain.UserStatus{},
+		Counts:  make(map[domain.Status]int),
+	}
+
+	members, err := s.presentMembers(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := s.statuses.completeStatuses(members)
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := s.statuses.publicStatuses(statuses, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range public {
+		// Invisible and hidden members stay in the room but are not shown
+		if !status.Status.IsPresent() {
+			continue
+		}
+		presence.Members = append(presence.Members, status)
+		presence.Counts[status.Status]++
+	}
+	presence.Online = len(presence.Members)
+
+	sort.Slice(presence.Members, func(i, j int) bool {
+		a, b := presence.Members[i], presence.Members[j]
+		if a.Status != b.Status {
+			return a.Status.Precedence() > b.Status.Precedence()
+		}
+		return a.UserID < b.UserID
+	})
+
+	return presence, nil
+}
+
+// GetRoomCount returns how many members of a room are visible as present to the
+// viewer, per status. It only reads what decides the counts (statuses, DND, quiet
+// hours and visibility rules), never last seen, custom statuses or privacy settings.
+func (s *RoomService) GetRoomCount(roomID, viewerID string) (*domain.RoomPresence, error) {
+	if err := validateRoomID(roomID); err != nil {
+		return nil, err
+	}
+	if err := s.statuses.validateViewerID(&viewerID); err != nil {
+		return nil, err
+	}
+
+	members, err := s.presentMembers(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := s.statuses.overlayStatuses(members)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(statuses))
+	for userID := range statuses {
+		ids = append(ids, userID)
+	}
+	rules, err := s.statuses.repo.GetMultipleVisibilityRules(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	count := &domain.RoomPresence{
+		RoomID: roomID,
+		Counts: make(map[domain.Status]int),
+	}
+	for userID, status := range statuses {
+		public := publicStatus(status)
+		applyVisibility(public, visibilityFor(rules[userID], viewerID))
+		if public.Status.IsPresent() {
+			count.Counts[public.Status]++
+			count.Online++
+		}
+	}
+	return count, nil
+}
+
+// presentMembers resolves the statuses of a room's members. Members whose status
+// went offline or expired are pruned from the room on the way.
+func (s *RoomService) presentMembers(roomID string) (map[string]domain.Status, error) {
+	memberIDs, err := s.repo.GetRoomMembers(roomID)
+	if err != nil {
+		return nil, err
+	}
+	if len(memberIDs) == 0 {
+		return make(map[string]domain.Status), nil
+	}
+
+	statuses, err := s.statuses.repo.GetMultipleUserStatus(memberIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var gone []string
+	for userID, status := range statuses {
+		if !status.IsPresent() {
+			gone = append(gone, userID)
+			delete(statuses, userID)
+		}
+	}
+	if err := s.repo.RemoveRoomMembers(roomID, gone); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// requirePresent returns ErrUserNotPresent if the user is offline or has no presence
+func (s *RoomService) requirePresent(userID string) error {
+	status, err := s.statuses.repo.GetUserStatus(userID)
+	if err != nil {
+		return err
+	}
+	if !status.IsPresent() {
+		return domain.ErrUserNotPresent
+	}
+	return nil
+}
+
+// validateRoomID validates a room ID
+func validateRoomID(roomID string) error {
+	return validateSpaceID("room", roomID)
+}
+
+// validateSpaceID validates the ID of a room or conversation
+func validateSpaceID(kind, id string) error {
+	if id == "" {
+		return invalidf("%s ID cannot be empty", kind)
+	}
+	if len(id) > MaxRoomIDLength {
+		return invalidf("%s ID too long (max %d characters)", kind, MaxRoomIDLength)
+	}
+	if !spaceIDPattern.MatchString(id) {
+		return invalidf("%s ID may only contain letters, digits, '_', '-' and '.'", kind)
+	}
+	return nil
+}
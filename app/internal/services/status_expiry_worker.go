@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"social-app/internal/domain"
+)
+
+// StatusExpiryWorker transitions expired statuses (online→away→offline) as soon as
+// Redis reports the expiry, instead of waiting for someone to read the key.
+// Safe to run on every instance: each expiry is claimed by exactly one of them.
+type StatusExpiryWorker struct {
+	repo  domain.UserStatusRepository
+	rooms domain.RoomRepository
+}
+
+// NewStatusExpiryWorker creates a new StatusExpiryWorker with the given status and room repositories
+func NewStatusExpiryWorker(repo domain.UserStatusRepository, rooms domain.RoomRepository) *StatusExpiryWorker {
+	return &StatusExpiryWorker{
+		repo:  repo,
+		rooms: rooms,
+	}
+}
+
+// Run processes expirations until ctx is cancelled
+func (w *StatusExpiryWorker) Run(ctx context.Context) error {
+	expiries, err := w.repo.SubscribeExpiredUserStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	for expiry := range expiries {
+		if expiry.DND {
+			w.handleDNDExpiry(expiry.UserID)
+			continue
+		}
+		w.handleExpiry(expiry.UserID)
+	}
+
+	return ctx.Err()
+}
+
+// handleExpiry transitions a single user, publishes the resulting status change and
+// removes users who went offline from their rooms
+func (w *StatusExpiryWorker) handleExpiry(userID string) {
+	event, err := w.repo.ExpireUserStatus(userID)
+	if err != nil {
+		log.Printf("❌ Failed to transition expired status for %s: %v", userID, err)
+		return
+	}
+	if event == nil {
+		// Claimed by another instance or nothing changed
+		return
+	}
+
+	w.publish(*event)
+
+	// Members drop out of rooms once their presence runs out
+	if !event.NewStatus.IsPresent() {
+		if err := w.rooms.LeaveAllRooms(userID); err != nil {
+			log.Printf("❌ Failed to remove %s from rooms: %v", userID, err)
+		}
+	}
+}
+
+// handleDNDExpiry records a do-not-disturb period that reached its deadline and
+// publishes the resulting status change
+func (w *StatusExpiryWorker) handleDNDExpiry(userID string) {
+	event, err := w.repo.ExpireDND(userID)
+	if err != nil {
+		log.Printf("❌ Failed to end DND for %s: %v", userID, err)
+		return
+	}
+	if event == nil {
+		// Recorded by another instance or nothing visible changed
+		return
+	}
+
+	w.publish(*event)
+}
+
+// publish masks a status change the way public reads are masked (invisible appears
+// offline) and publishes it unless nothing visible changed
+func (w *StatusExpiryWorker) publish(event domain.StatusEvent) {
+	event.OldStatus = event.OldStatus.Public()
+	event.NewStatus = event.NewStatus.Public()
+	if event.OldStatus == event.NewStatus {
+		return
+	}
+
+	if err := w.repo.PublishStatusEvent(event); err != nil {
+		log.Printf("❌ Failed to publish status event for %s: %v", event.UserID, err)
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+
+	"social-app/internal/domain"
+)
+
+// Status history page sizes
+const (
+	DefaultStatusHistoryLimit = 50
+	MaxStatusHistoryLimit     = 200
+)
+
+// GetStatusHistory returns a page of user's status changes (newest first) and the
+// cursor for the next page, empty when there are no more entries
+func (s *UserStatusService) GetStatusHistory(userID string, query domain.StatusHistoryQuery) ([]domain.StatusHistoryEntry, string, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return nil, "", err
+	}
+	if err := validateStatusHistoryQuery(&query); err != nil {
+		return nil, "", err
+	}
+
+	// Fetch one extra entry to know whether another page exists
+	limit := query.Limit
+	query.Limit++
+	entries, err := s.repo.GetStatusHistory(userID, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if int64(len(entries)) > limit {
+		entries = entries[:limit]
+		nextCursor = entries[limit-1].ID
+	}
+
+	return entries, nextCursor, nil
+}
+
+// validateStatusHistoryQuery validates a history page request and fills in the default limit
+func validateStatusHistoryQuery(query *domain.StatusHistoryQuery) error {
+	if query.Limit == 0 {
+		query.Limit = DefaultStatusHistoryLimit
+	}
+	if query.Limit < 0 || query.Limit > MaxStatusHistoryLimit {
+		return invalidf("limit must be between 1 and 200")
+	}
+	if query.From != nil && query.To != nil && query.From.After(*query.To) {
+		return invalidf("from must be before to")
+	}
+	if query.Cursor != "" && !isStreamID(query.Cursor) {
+		return invalidf("invalid cursor")
+	}
+	return nil
+}
+
+// isStreamID reports whether id is a Redis stream entry ID ({ms}-{seq}), the format of history cursors
+func isStreamID(id string) bool {
+	millis, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	if _, err := strconv.ParseUint(millis, 10, 64); err != nil {
+		return false
+	}
+	_, err := strconv.ParseUint(seq, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"social-app/internal/domain"
+)
+
+// TypingService shows who is typing in a conversation. Only users others see as
+// present are shown, so statuses are read and masked through the UserStatusService.
+type TypingService struct {
+	repo     domain.TypingRepository
+	statuses *UserStatusService
+}
+
+// NewTypingService creates a new TypingService with the given typing repository and user status service
+func NewTypingService(repo domain.TypingRepository, statuses *UserStatusService) *TypingService {
+	return &TypingService{
+		repo:     repo,
+		statuses: statuses,
+	}
+}
+
+// StartTyping shows user as typing in a conversation for domain.TypingTTL and
+// notifies the other members. Clients repeat it while the user keeps typing.
+// Invisible users are never shown typing, so it returns false without recording anything;
+// offline users and users without presence get ErrUserNotPresent.
+func (s *TypingService) StartTyping(conversationID, userID string) (bool, error) {
+	if err := validateConversationID(conversationID); err != nil {
+		return false, err
+	}
+	if err := s.statuses.validateUserID(&userID); err != nil {
+		return false, err
+	}
+
+	status, err := s.statuses.repo.GetUserStatus(userID)
+	if err != nil {
+		return false, err
+	}
+	if !status.IsPresent() {
+		return false, domain.ErrUserNotPresent
+	}
+	if !status.Public().IsPresent() {
+		return false, nil
+	}
+
+	if err := s.repo.StartTyping(conversationID, userID, domain.TypingTTL); err != nil {
+		return false, err
+	}
+
+	s.publishTyping(userID, domain.TypingEvent{
+		ConversationID: conversationID,
+		UserID:         userID,
+		Typing:         true,
+		ExpiresInMs:    domain.TypingTTL.Milliseconds(),
+		Timestamp:      time.Now(),
+	})
+	return true, nil
+}
+
+// StopTyping clears user's typing indicator and notifies the other members
+func (s *TypingService) StopTyping(conversationID, userID string) error {
+	if err := validateConversationID(conversationID); err != nil {
+		return err
+	}
+	if err := s.statuses.validateUserID(&userID); err != nil {
+		return err
+	}
+
+	wasTyping, err := s.repo.StopTyping(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if !wasTyping {
+		return nil
+	}
+
+	s.publishTyping(userID, domain.TypingEvent{
+		ConversationID: conversationID,
+		UserID:         userID,
+		Typing:         false,
+		Timestamp:      time.Now(),
+	})
+	return nil
+}
+
+// GetTypingUsers returns who is typing in a conversation as visible to the viewer.
+// Users the viewer does not see as present (invisible, hidden by visibility rules)
+// are left out, as is the viewer.
+func (s *TypingService) GetTypingUsers(conversationID, viewerID string) (*domain.TypingState, error) {
+	if err := validateConversationID(conversationID); err != nil {
+		return nil, err
+	}
+	if err := s.statuses.validateViewerID(&viewerID); err != nil {
+		return nil, err
+	}
+
+	state := &domain.TypingState{
+		ConversationID: conversationID,
+		UserIDs:        []string{},
+	}
+
+	userIDs, err := s.repo.GetTypingUsers(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if len(userIDs) == 0 {
+		return state, nil
+	}
+
+	statuses, err := s.statuses.loadStatuses(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	public, err := s.statuses.publicStatuses(statuses, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	for userID, status := range public {
+		if userID != viewerID && status.Status.IsPresent() {
+			state.UserIDs = append(state.UserIDs, userID)
+		}
+	}
+	sort.Strings(state.UserIDs)
+
+	return state, nil
+}
+
+// publishTyping fans a typing change out over Pub/Sub, limited to the members the
+// user's visibility rules show them to. Typing is low priority, so failures are
+// logged rather than failing the request.
+func (s *TypingService) publishTyping(userID string, event domain.TypingEvent) {
+	rules, err := s.statuses.repo.GetVisibilityRules(userID)
+	if err != nil {
+		log.Printf("❌ Failed to load visibility rules for %s: %v", userID, err)
+		return
+	}
+	if rules != nil {
+		exceptions := visibilityExceptions(rules)
+		if rules.Default == domain.VisibilityVisible {
+			event.HiddenFrom = exceptions
+		} else if len(exceptions) == 0 {
+			return // Hidden from everyone
+		} else {
+			event.VisibleTo = exceptions
+		}
+	}
+
+	if err := s.repo.PublishTypingEvent(event); err != nil {
+		log.Printf("❌ Failed to publish typing event for %s: %v", event.UserID, err)
+	}
+}
+
+// validateConversationID validates a conversation ID
+func validateConversationID(conversationID string) error {
+	return validateSpaceID("conversation", conversationID)
+}
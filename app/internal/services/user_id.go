@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"social-app/internal/domain"
+)
+
+// uuidPattern matches a UUID in canonical 8-4-4-4-12 form (after case folding)
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// NewUserIDValidator creates the user ID validator for a configured scheme
+func NewUserIDValidator(scheme domain.UserIDScheme) (domain.UserIDValidator, error) {
+	switch scheme.Kind {
+	case domain.UserIDSchemePrefix:
+		if scheme.Prefix == "" {
+			return nil, errors.New("user ID prefix cannot be empty")
+		}
+		return &prefixIDValidator{prefix: scheme.Prefix, maxLength: scheme.MaxLength, foldCase: scheme.FoldCase}, nil
+	case domain.UserIDSchemeUUID:
+		return uuidIDValidator{}, nil
+	case domain.UserIDSchemeSnowflake:
+		return snowflakeIDValidator{}, nil
+	case domain.UserIDSchemeRegex:
+		pattern, err := regexp.Compile(`^(?:` + scheme.Pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID pattern: %w", err)
+		}
+		return &regexIDValidator{pattern: pattern, maxLength: scheme.MaxLength, foldCase: scheme.FoldCase}, nil
+	default:
+		return nil, fmt.Errorf("unknown user ID scheme %q: must be prefix, uuid, snowflake, or regex", scheme.Kind)
+	}
+}
+
+// trimUserID trims surrounding whitespace and rejects empty IDs
+func trimUserID(userID string) (string, error) {
+	if userID == "" {
+		return "", errors.New("user ID cannot be empty")
+	}
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return "", errors.New("user ID cannot be whitespace only")
+	}
+	return userID, nil
+}
+
+// checkUserIDChars rejects characters that cannot appear in a Redis key part:
+// ':' separates key parts, and whitespace never survives a round trip through URLs and CSV lists
+func checkUserIDChars(userID string) error {
+	if strings.ContainsRune(userID, ':') || strings.ContainsFunc(userID, unicode.IsSpace) {
+		return errors.New("user ID must not contain ':' or whitespace")
+	}
+	return nil
+}
+
+// checkUserIDLength enforces the configured maximum length (0 = unlimited)
+func checkUserIDLength(userID string, maxLength int) error {
+	if maxLength > 0 && len(userID) > maxLength {
+		return fmt.Errorf("user ID too long (max %d characters)", maxLength)
+	}
+	return nil
+}
+
+// prefixIDValidator accepts IDs starting with a fixed prefix, e.g. user_123
+type prefixIDValidator struct {
+	prefix    string
+	maxLength int
+	foldCase  bool
+}
+
+func (v *prefixIDValidator) Normalize(userID string) (string, error) {
+	userID, err := trimUserID(userID)
+	if err != nil {
+		return "", err
+	}
+	if v.foldCase {
+		userID = strings.ToLower(userID)
+	}
+
+	if !strings.HasPrefix(userID, v.prefix) || len(userID) == len(v.prefix) {
+		return "", fmt.Errorf("user ID must start with '%s'", v.prefix)
+	}
+	if err := checkUserIDChars(userID); err != nil {
+		return "", err
+	}
+	return userID, checkUserIDLength(userID, v.maxLength)
+}
+
+// uuidIDValidator accepts UUIDs and normalizes them to lower case
+type uuidIDValidator struct{}
+
+func (uuidIDValidator) Normalize(userID string) (string, error) {
+	userID, err := trimUserID(userID)
+	if err != nil {
+		return "", err
+	}
+
+	userID = strings.ToLower(userID)
+	if !uuidPattern.MatchString(userID) {
+		return "", errors.New("user ID must be a UUID")
+	}
+	return userID, nil
+}
+
+// snowflakeIDValidator accepts numeric 64-bit IDs and strips leading zeros
+type snowflakeIDValidator struct{}
+
+func (snowflakeIDValidator) Normalize(userID string) (string, error) {
+	userID, err := trimUserID(userID)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil || id == 0 {
+		return "", errors.New("user ID must be a positive numeric snowflake ID")
+	}
+	return strconv.FormatUint(id, 10), nil
+}
+
+// regexIDValidator accepts IDs matching a configured pattern
+type regexIDValidator struct {
+	pattern   *regexp.Regexp
+	maxLength int
+	foldCase  bool
+}
+
+func (v *regexIDValidator) Normalize(userID string) (string, error) {
+	userID, err := trimUserID(userID)
+	if err != nil {
+		return "", err
+	}
+	if v.foldCase {
+		userID = strings.ToLower(userID)
+	}
+
+	if !v.pattern.MatchString(userID) {
+		return "", errors.New("user ID has an invalid format")
+	}
+	if err := checkUserIDChars(userID); err != nil {
+		return "", err
+	}
+	return userID, checkUserIDLength(userID, v.maxLength)
+}
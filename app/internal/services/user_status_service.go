@@ -1,168 +1,628 @@
 package services
 
 import (
-	"errors"
+	"fmt"
 	"social-app/internal/domain"
 	"strings"
 	"time"
 )
 
 type UserStatusService struct {
-	repo domain.UserStatusRepository
+	repo     domain.UserStatusRepository
+	contacts domain.ContactsRepository
+	policy   domain.PresencePolicy
+	ids      domain.UserIDValidator
+	load     *heartbeatLoad
+	maxBatch int
 }
 
-// NewUserStatusService creates a new UserStatusService with the given repository
-func NewUserStatusService(repo domain.UserStatusRepository) *UserStatusService {
+// NewUserStatusService creates a new UserStatusService with the given repository,
+// contacts repository (for contacts-only last seen), presence policy, user ID
+// validator and maximum user IDs per bulk request
+func NewUserStatusService(repo domain.UserStatusRepository, contacts domain.ContactsRepository, policy domain.PresencePolicy, ids domain.UserIDValidator, maxBatch int) *UserStatusService {
+	if maxBatch <= 0 {
+		maxBatch = domain.DefaultBulkMaxUserIDs
+	}
 	return &UserStatusService{
-		repo: repo,
+		repo:     repo,
+		contacts: contacts,
+		policy:   policy,
+		ids:      ids,
+		load:     &heartbeatLoad{},
+		maxBatch: maxBatch,
 	}
 }
 
 // Business methods
-func (s *UserStatusService) SetUserStatus(userID string, status string) error {
-	if err := s.validateUserID(userID); err != nil {
-		return err
+func (s *UserStatusService) SetUserStatus(userID string, session domain.ClientSession, status domain.Status) error {
+	_, err := s.SetUserStatusIfVersion(userID, session, status, domain.AnyVersion)
+	return err
+}
+
+// SetUserStatusIfVersion sets the status only if the user's presence version still equals
+// version (domain.AnyVersion = unconditional) and returns the new version.
+// Returns domain.ErrVersionConflict if another client changed the presence meanwhile.
+func (s *UserStatusService) SetUserStatusIfVersion(userID string, session domain.ClientSession, status domain.Status, version int64) (int64, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return 0, err
+	}
+	if err := s.validateSession(&session); err != nil {
+		return 0, err
 	}
 
-	// Determine appropriate TTL based on status
-	var ttl time.Duration
-	switch status {
-	case domain.StatusOnline:
-		ttl = domain.OnlineTTL
-	case domain.StatusAway:
-		ttl = domain.AwayTTL
-	case domain.StatusOffline:
-		ttl = domain.OfflineTTL
-	case domain.StatusInvisible:
-		ttl = domain.OnlineTTL // Same as online but appears offline
-	case domain.StatusDND:
-		ttl = domain.OnlineTTL // Same as online but limited notifications
-	default:
-		return errors.New("invalid status: must be online, away, offline, invisible, or dnd")
+	if _, err := domain.ParseStatus(string(status)); err != nil {
+		return 0, domain.InvalidInput(err)
+	}
+
+	// DND is a user-level choice that survives heartbeats, not a session status
+	if !status.IsSessionStatus() {
+		_, newVersion, err := s.setUserDND(userID, nil, version)
+		return newVersion, err
 	}
 
-	return s.repo.SetUserStatus(userID, status, ttl)
+	return s.repo.CompareAndSetSessionStatus(userID, s.sessionPresence(session, status), s.sessionTTL(session, status), version)
 }
 
-func (s *UserStatusService) SetUserOffline(userID string) error {
-	if err := s.validateUserID(userID); err != nil {
+func (s *UserStatusService) SetUserOffline(userID string, session domain.ClientSession) error {
+	if err := s.validateUserID(&userID); err != nil {
+		return err
+	}
+	if err := s.validateSession(&session); err != nil {
 		return err
 	}
-	return s.repo.SetUserStatus(userID, domain.StatusOffline, domain.OfflineTTL)
+	return s.setSessionStatus(userID, session, domain.StatusOffline)
 }
 
-func (s *UserStatusService) SetUserAway(userID string) error {
-	if err := s.validateUserID(userID); err != nil {
+func (s *UserStatusService) SetUserAway(userID string, session domain.ClientSession) error {
+	if err := s.validateUserID(&userID); err != nil {
 		return err
 	}
-	return s.repo.SetUserStatus(userID, domain.StatusAway, domain.AwayTTL)
+	if err := s.validateSession(&session); err != nil {
+		return err
+	}
+	return s.setSessionStatus(userID, session, domain.StatusAway)
 }
 
-func (s *UserStatusService) SetUserInvisible(userID string) error {
-	if err := s.validateUserID(userID); err != nil {
+func (s *UserStatusService) SetUserInvisible(userID string, session domain.ClientSession) error {
+	if err := s.validateUserID(&userID); err != nil {
+		return err
+	}
+	if err := s.validateSession(&session); err != nil {
 		return err
 	}
-	return s.repo.SetUserStatus(userID, domain.StatusInvisible, domain.OnlineTTL)
+	return s.setSessionStatus(userID, session, domain.StatusInvisible)
 }
 
-func (s *UserStatusService) SetUserDND(userID string) error {
-	if err := s.validateUserID(userID); err != nil {
+// EndSession logs a client session out, dropping any status chosen on it
+func (s *UserStatusService) EndSession(userID string, sessionID string) error {
+	if err := s.validateUserID(&userID); err != nil {
 		return err
 	}
-	return s.repo.SetUserStatus(userID, domain.StatusDND, domain.OnlineTTL)
+	session := domain.ClientSession{SessionID: sessionID}
+	if err := s.validateSession(&session); err != nil {
+		return err
+	}
+	return s.repo.EndSession(userID, session.SessionID)
+}
+
+// setSessionStatus writes the status for one client session with the policy TTL
+func (s *UserStatusService) setSessionStatus(userID string, session domain.ClientSession, status domain.Status) error {
+	return s.repo.SetSessionStatus(userID, s.sessionPresence(session, status), s.sessionTTL(session, status))
+}
+
+// sessionPresence builds the presence record of an explicitly set session status
+func (s *UserStatusService) sessionPresence(session domain.ClientSession, status domain.Status) domain.SessionPresence {
+	return domain.SessionPresence{
+		SessionID:  session.SessionID,
+		DeviceType: session.DeviceType,
+		Status:     status,
+		Source:     statusSource(status),
+	}
+}
+
+// sessionTTL returns the policy TTL of a session status at the current load
+func (s *UserStatusService) sessionTTL(session domain.ClientSession, status domain.Status) time.Duration {
+	return s.policy.SessionTTL(status, session.DeviceType, s.loadMultiplier())
+}
+
+// loadMultiplier returns how much heartbeat intervals are stretched at the current load
+func (s *UserStatusService) loadMultiplier() float64 {
+	return s.policy.LoadMultiplier(s.load.current(time.Now()))
+}
+
+// statusSource tells whether an explicitly set status is a manual choice that
+// heartbeats must not override
+func statusSource(status domain.Status) string {
+	if status.IsManual() {
+		return domain.SourceManual
+	}
+	return domain.SourceAuto
 }
 
 func (s *UserStatusService) GetUserStatus(userID string) (*domain.UserStatus, error) {
-	if err := s.validateUserID(userID); err != nil {
+	if err := s.validateUserID(&userID); err != nil {
 		return nil, err
 	}
 
-	status, err := s.repo.GetUserStatus(userID)
+	status, version, err := s.repo.GetUserStatusVersion(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &domain.UserStatus{
-		UserID:    userID,
-		Status:    status,
-		Timestamp: time.Now(),
-	}, nil
-}
+	sessions, err := s.repo.GetUserSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	lastSeen, err := s.repo.GetLastSeen(userID)
+	if err != nil {
+		return nil, err
+	}
 
-// GetPublicUserStatus returns status visible to other users (handles invisible mode)
-func (s *UserStatusService) GetPublicUserStatus(userID string) (*domain.UserStatus, error) {
-	if err := s.validateUserID(userID); err != nil {
+	custom, err := s.repo.GetCustomStatus(userID)
+	if err != nil {
 		return nil, err
 	}
 
-	status, err := s.repo.GetUserStatus(userID)
+	dnd, err := s.repo.GetDND(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// If user is invisible, show as offline to others
-	publicStatus := status
-	if status == domain.StatusInvisible {
-		publicStatus = domain.StatusOffline
+	schedule, err := s.repo.GetQuietHours(userID)
+	if err != nil {
+		return nil, err
 	}
 
-	return &domain.UserStatus{
+	result := &domain.UserStatus{
 		UserID:       userID,
-		Status:       publicStatus,
-		ActualStatus: status, // Store actual status for internal use
+		Status:       status,
 		Timestamp:    time.Now(),
-	}, nil
+		LastActivity: lastSeen,
+		CustomStatus: custom,
+		Sessions:     sessions,
+		Version:      version,
+	}
+	applyDND(result, dnd)
+	applyQuietHours(result, schedule)
+
+	return result, nil
 }
 
-func (s *UserStatusService) GetMultipleUserStatus(userIDs []string) (map[string]*domain.UserStatus, error) {
+// GetPublicUserStatus returns status as visible to the viewer: invisible mode
+// applies to everyone, then the user's visibility rules and last seen privacy for the viewer.
+// An empty viewer ID is an anonymous viewer.
+func (s *UserStatusService) GetPublicUserStatus(userID, viewerID string) (*domain.UserStatus, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return nil, err
+	}
+	if err := s.validateViewerID(&viewerID); err != nil {
+		return nil, err
+	}
+
+	status, err := s.GetUserStatus(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := s.repo.GetVisibilityRules(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	public := publicStatus(status)
+	applyVisibility(public, visibilityFor(rules, viewerID))
+
+	if public.LastActivity != nil {
+		visible, err := s.lastSeenVisible(userID, viewerID)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			public.LastActivity = nil
+		}
+	}
+
+	return public, nil
+}
+
+// publicStatus masks a status for other users: invisible users show as offline
+// without custom status, and sessions, version and actual status are never exposed
+func publicStatus(status *domain.UserStatus) *domain.UserStatus {
+	public := &domain.UserStatus{
+		UserID:       status.UserID,
+		Status:       status.Status,
+		Timestamp:    status.Timestamp,
+		LastActivity: status.LastActivity,
+		CustomStatus: status.CustomStatus,
+		DNDUntil:     status.DNDUntil,
+	}
+	if shown := status.Status.Public(); shown != status.Status {
+		public.Status = shown
+		public.CustomStatus = nil
+	}
+	return public
+}
+
+// GetLastSeen returns when a user was last active, as seen by the viewer
+func (s *UserStatusService) GetLastSeen(userID, viewerID string) (*domain.LastSeen, error) {
+	status, err := s.GetPublicUserStatus(userID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.LastSeen{
+		UserID:   status.UserID,
+		Status:   status.Status,
+		LastSeen: status.LastActivity,
+	}
+	if status.LastActivity != nil {
+		result.SecondsAgo = int64(time.Since(*status.LastActivity).Seconds())
+	}
+
+	return result, nil
+}
+
+// GetMultipleUserStatus returns statuses of up to maxBatch users. Invalid IDs are
+// reported per ID in the result instead of failing the whole batch.
+func (s *UserStatusService) GetMultipleUserStatus(userIDs []string) (*domain.BulkStatusResult, error) {
+	normalized, invalid, err := s.normalizeUserIDs(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := s.loadStatuses(normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.BulkStatusResult{Statuses: statuses, Errors: invalid}, nil
+}
+
+// normalizeUserIDs validates the IDs of a bulk request, returning the distinct
+// normalized IDs and the reason each invalid input ID was rejected
+func (s *UserStatusService) normalizeUserIDs(userIDs []string) ([]string, map[string]string, error) {
 	if len(userIDs) == 0 {
-		return nil, errors.New("user IDs cannot be empty")
+		return nil, nil, invalidf("user IDs cannot be empty")
+	}
+	if len(userIDs) > s.maxBatch {
+		return nil, nil, fmt.Errorf("%w (max %d)", domain.ErrTooManyUserIDs, s.maxBatch)
+	}
+
+	normalized := make([]string, 0, len(userIDs))
+	seen := make(map[string]bool, len(userIDs))
+	invalid := make(map[string]string)
+	for _, raw := range userIDs {
+		userID := raw
+		if err := s.validateUserID(&userID); err != nil {
+			invalid[raw] = err.Error()
+			continue
+		}
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		normalized = append(normalized, userID)
+	}
+
+	return normalized, invalid, nil
+}
+
+// loadStatuses loads full statuses of already validated user IDs
+func (s *UserStatusService) loadStatuses(userIDs []string) (map[string]*domain.UserStatus, error) {
+	if len(userIDs) == 0 {
+		return make(map[string]*domain.UserStatus), nil
 	}
 
 	statuses, err := s.repo.GetMultipleUserStatus(userIDs)
 	if err != nil {
 		return nil, err
 	}
+	return s.completeStatuses(statuses)
+}
+
+// completeStatuses adds last seen and custom statuses to resolved statuses and
+// overlays DND and quiet hours
+func (s *UserStatusService) completeStatuses(statuses map[string]domain.Status) (map[string]*domain.UserStatus, error) {
+	result, err := s.overlayStatuses(statuses)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return result, nil
+	}
+
+	userIDs := make([]string, 0, len(statuses))
+	for userID := range statuses {
+		userIDs = append(userIDs, userID)
+	}
+
+	lastSeen, err := s.repo.GetMultipleLastSeen(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	customs, err := s.repo.GetMultipleCustomStatus(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for userID, status := range result {
+		status.LastActivity = lastSeen[userID]
+		status.CustomStatus = customs[userID]
+	}
+	return result, nil
+}
+
+// overlayStatuses overlays DND and quiet hours on resolved statuses, which is all
+// that decides the status others see
+func (s *UserStatusService) overlayStatuses(statuses map[string]domain.Status) (map[string]*domain.UserStatus, error) {
+	result := make(map[string]*domain.UserStatus, len(statuses))
+	if len(statuses) == 0 {
+		return result, nil
+	}
+
+	userIDs := make([]string, 0, len(statuses))
+	for userID := range statuses {
+		userIDs = append(userIDs, userID)
+	}
+
+	dnds, err := s.repo.GetMultipleDND(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules, err := s.repo.GetMultipleQuietHours(userIDs)
+	if err != nil {
+		return nil, err
+	}
 
-	result := make(map[string]*domain.UserStatus)
 	for userID, status := range statuses {
 		result[userID] = &domain.UserStatus{
 			UserID:    userID,
 			Status:    status,
 			Timestamp: time.Now(),
 		}
+		applyDND(result[userID], dnds[userID])
+		applyQuietHours(result[userID], schedules[userID])
 	}
+	return result, nil
+}
 
+// GetMultiplePublicUserStatus returns statuses of multiple users as visible to
+// the viewer, masking each one like GetPublicUserStatus (e.g. for friend lists)
+func (s *UserStatusService) GetMultiplePublicUserStatus(userIDs []string, viewerID string) (*domain.BulkStatusResult, error) {
+	if err := s.validateViewerID(&viewerID); err != nil {
+		return nil, err
+	}
+
+	result, err := s.GetMultipleUserStatus(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Statuses, err = s.publicStatuses(result.Statuses, viewerID)
+	if err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
-// SendHeartbeat refreshes user online status TTL
-func (s *UserStatusService) SendHeartbeat(userID string) error {
-	if err := s.validateUserID(userID); err != nil {
-		return err
+// publicStatuses masks already loaded statuses for the viewer like GetPublicUserStatus
+func (s *UserStatusService) publicStatuses(statuses map[string]*domain.UserStatus, viewerID string) (map[string]*domain.UserStatus, error) {
+	ids := make([]string, 0, len(statuses))
+	for userID := range statuses {
+		ids = append(ids, userID)
+	}
+	rules, err := s.repo.GetMultipleVisibilityRules(ids)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.repo.RefreshUserStatusTTL(userID, domain.OnlineTTL)
+	lastSeenVisible, err := s.lastSeenVisibleMultiple(ids, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*domain.UserStatus, len(statuses))
+	for userID, status := range statuses {
+		result[userID] = publicStatus(status)
+		applyVisibility(result[userID], visibilityFor(rules[userID], viewerID))
+		if !lastSeenVisible[userID] {
+			result[userID].LastActivity = nil
+		}
+	}
+
+	return result, nil
 }
 
-// validateUserID validates user ID format
-func (s *UserStatusService) validateUserID(userID string) error {
+// SendHeartbeat refreshes a single client session and returns the effective status
+// together with the interval the client should send its next heartbeat after.
+// Client-reported activity decides online vs away; without it the session is online.
+// The interval adapts to device type, the session's status and load, and the session
+// TTL is extended to cover it.
+func (s *UserStatusService) SendHeartbeat(userID string, session domain.ClientSession, activity *domain.HeartbeatActivity) (*domain.HeartbeatResult, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return nil, err
+	}
+	if err := s.validateSession(&session); err != nil {
+		return nil, err
+	}
+	if err := validateActivity(activity); err != nil {
+		return nil, err
+	}
 
-	if userID == "" {
-		return errors.New("user ID cannot be empty")
+	status := activityStatus(activity, s.policy.IdleAwayThreshold)
+	load := s.policy.LoadMultiplier(s.load.record(time.Now()))
+	ttl := s.policy.SessionTTL(status, session.DeviceType, load)
+
+	var idle time.Duration
+	if activity != nil {
+		idle = time.Duration(activity.IdleSeconds) * time.Second
 	}
 
-	if strings.TrimSpace(userID) == "" {
-		return errors.New("user ID cannot be whitespace only")
+	sessionStatus, effective, err := s.repo.RefreshSessionTTL(userID, session, status, idle, ttl)
+	if err != nil {
+		return nil, err
 	}
 
-	if !strings.HasPrefix(userID, "user_") {
-		return errors.New("user ID must start with 'user_'")
+	return &domain.HeartbeatResult{
+		Status:            effective,
+		HeartbeatInterval: s.policy.NextHeartbeatInterval(session.DeviceType, sessionStatus, load),
+	}, nil
+}
+
+// SendBulkHeartbeat refreshes up to maxBatch sessions in one Redis round trip, so a
+// connection gateway can keep every user of its node present. Each heartbeat follows
+// SendHeartbeat (activity, away→online, adaptive interval); an invalid or failed one
+// is reported in its own result instead of failing the batch. Results follow the
+// order of heartbeats.
+func (s *UserStatusService) SendBulkHeartbeat(heartbeats []domain.BulkHeartbeat) ([]domain.BulkHeartbeatResult, error) {
+	if len(heartbeats) == 0 {
+		return nil, invalidf("heartbeats cannot be empty")
+	}
+	if len(heartbeats) > s.maxBatch {
+		return nil, fmt.Errorf("%w (max %d)", domain.ErrTooManyUserIDs, s.maxBatch)
+	}
+
+	results := make([]domain.BulkHeartbeatResult, len(heartbeats))
+	refreshes := make([]domain.SessionRefresh, 0, len(heartbeats))
+	positions := make([]int, 0, len(heartbeats))
+	for i, heartbeat := range heartbeats {
+		err := s.validateUserID(&heartbeat.UserID)
+		if err == nil {
+			err = s.validateSession(&heartbeat.Session)
+		}
+		if err == nil {
+			err = validateActivity(heartbeat.Activity)
+		}
+		results[i] = domain.BulkHeartbeatResult{
+			UserID:    heartbeat.UserID,
+			SessionID: heartbeat.Session.SessionID,
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		var idle time.Duration
+		if heartbeat.Activity != nil {
+			idle = time.Duration(heartbeat.Activity.IdleSeconds) * time.Second
+		}
+		refreshes = append(refreshes, domain.SessionRefresh{
+			UserID:  heartbeat.UserID,
+			Session: heartbeat.Session,
+			Status:  activityStatus(heartbeat.Activity, s.policy.IdleAwayThreshold),
+			Idle:    idle,
+		})
+		positions = append(positions, i)
+	}
+	if len(refreshes) == 0 {
+		return results, nil
+	}
+
+	load := s.policy.LoadMultiplier(s.load.recordN(time.Now(), len(refreshes)))
+	for i := range refreshes {
+		refreshes[i].TTL = s.policy.SessionTTL(refreshes[i].Status, refreshes[i].Session.DeviceType, load)
+	}
+
+	refreshed, err := s.repo.RefreshSessionTTLs(refreshes)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, refresh := range refreshes {
+		result := &results[positions[i]]
+		if refreshed[i].Err != nil {
+			result.Error = refreshed[i].Err.Error()
+			continue
+		}
+		result.Status = refreshed[i].Status
+		result.HeartbeatInterval = s.policy.NextHeartbeatInterval(refresh.Session.DeviceType, refreshed[i].SessionStatus, load)
+	}
+
+	return results, nil
+}
+
+// GetPresencePolicy returns the presence policy as it applies to a client of the given device type
+func (s *UserStatusService) GetPresencePolicy(deviceType string) (*domain.PresencePolicyView, error) {
+	session := domain.ClientSession{DeviceType: deviceType}
+	if err := s.validateSession(&session); err != nil {
+		return nil, err
 	}
 
-	if len(userID) > 50 {
-		return errors.New("user ID too long (max 50 characters)")
+	view := s.policy.View(deviceType, s.loadMultiplier())
+	return &view, nil
+}
+
+// activityStatus derives the active or idle status from client-reported activity
+// ("5 min idle → Away"; a backgrounded client without input is idle too)
+func activityStatus(activity *domain.HeartbeatActivity, idleAway time.Duration) domain.Status {
+	return domain.ActivityStatus(isIdle(activity, idleAway))
+}
+
+// isIdle reports whether client-reported activity means the user is idle
+func isIdle(activity *domain.HeartbeatActivity, idleAway time.Duration) bool {
+	if activity == nil {
+		return false
 	}
+	if activity.InputActive != nil && *activity.InputActive {
+		return false
+	}
+	if time.Duration(activity.IdleSeconds)*time.Second >= idleAway {
+		return true
+	}
+	return activity.Foreground != nil && !*activity.Foreground
+}
 
+// invalidf formats an error caused by the request (see domain.ErrInvalidInput)
+func invalidf(format string, args ...any) error {
+	return domain.InvalidInput(fmt.Errorf(format, args...))
+}
+
+// validateActivity validates client-reported heartbeat activity
+func validateActivity(activity *domain.HeartbeatActivity) error {
+	if activity == nil {
+		return nil
+	}
+	if activity.IdleSeconds < 0 {
+		return invalidf("idle_seconds cannot be negative")
+	}
+	if time.Duration(activity.IdleSeconds)*time.Second > domain.LastSeenTTL {
+		return invalidf("idle_seconds too large")
+	}
 	return nil
 }
+
+// validateUserID validates the user ID and replaces it with its canonical form
+func (s *UserStatusService) validateUserID(userID *string) error {
+	normalized, err := s.ids.Normalize(*userID)
+	if err != nil {
+		return domain.InvalidInput(err)
+	}
+	*userID = normalized
+	return nil
+}
+
+// validateSession validates the client session and fills in the default session ID
+func (s *UserStatusService) validateSession(session *domain.ClientSession) error {
+	session.SessionID = strings.TrimSpace(session.SessionID)
+	if session.SessionID == "" {
+		session.SessionID = domain.DefaultSessionID
+	}
+
+	if len(session.SessionID) > 64 {
+		return invalidf("session ID too long (max 64 characters)")
+	}
+
+	if strings.ContainsAny(session.SessionID, ": ") {
+		return invalidf("session ID must not contain ':' or spaces")
+	}
+
+	switch session.DeviceType {
+	case "", domain.DeviceDesktop, domain.DeviceWeb, domain.DeviceMobile, domain.DeviceUnknown:
+		return nil
+	default:
+		return invalidf("invalid device type: must be desktop, web, mobile, or unknown")
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"social-app/internal/domain"
+)
+
+// Limits for visibility rules
+const (
+	MaxVisibilityRules   = 20
+	MaxVisibilityViewers = 1000 // Viewers per rule
+	MaxVisibilityName    = 50
+)
+
+// SetVisibilityRules creates or replaces user's viewer-specific visibility rules
+func (s *UserStatusService) SetVisibilityRules(userID string, rules domain.VisibilityRules) (*domain.VisibilityRules, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return nil, err
+	}
+	if err := s.validateVisibilityRules(&rules); err != nil {
+		return nil, err
+	}
+
+	rules.UserID = userID
+	rules.UpdatedAt = time.Now()
+	if err := s.repo.SetVisibilityRules(userID, rules); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// GetVisibilityRules returns user's visibility rules, or nil if none are configured
+func (s *UserStatusService) GetVisibilityRules(userID string) (*domain.VisibilityRules, error) {
+	if err := s.validateUserID(&userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetVisibilityRules(userID)
+}
+
+// DeleteVisibilityRules removes user's visibility rules, making presence visible to everyone again
+func (s *UserStatusService) DeleteVisibilityRules(userID string) error {
+	if err := s.validateUserID(&userID); err != nil {
+		return err
+	}
+	return s.repo.DeleteVisibilityRules(userID)
+}
+
+// visibilityFor evaluates user's rules for a viewer. An empty viewer is anonymous and
+// only matches the default. The viewer header is not authenticated, so a viewer
+// claiming to be the user gets no special treatment; users read their own status
+// through the raw status endpoint.
+func visibilityFor(rules *domain.VisibilityRules, viewerID string) domain.Visibility {
+	if rules == nil {
+		return domain.VisibilityVisible
+	}
+
+	if viewerID != "" {
+		for _, rule := range rules.Rules {
+			for _, viewer := range rule.Viewers {
+				if viewer == viewerID {
+					return rule.Visibility
+				}
+			}
+		}
+	}
+
+	return rules.Default
+}
+
+// visibilityExceptions lists the viewers the rules show the user to differently
+// than the default: hidden viewers when visible by default, visible ones otherwise.
+// Viewers are evaluated like visibilityFor, so the first matching rule wins.
+func visibilityExceptions(rules *domain.VisibilityRules) []string {
+	shownByDefault := rules.Default == domain.VisibilityVisible
+
+	var exceptions []string
+	seen := make(map[string]bool)
+	for _, rule := range rules.Rules {
+		for _, viewer := range rule.Viewers {
+			if seen[viewer] {
+				continue
+			}
+			seen[viewer] = true
+			if (rule.Visibility == domain.VisibilityVisible) != shownByDefault {
+				exceptions = append(exceptions, viewer)
+			}
+		}
+	}
+	return exceptions
+}
+
+// applyVisibility masks an already public status according to the viewer's visibility
+func applyVisibility(status *domain.UserStatus, visibility domain.Visibility) {
+	switch visibility {
+	case domain.VisibilityOffline:
+		status.Status = domain.StatusOffline
+	case domain.VisibilityHidden:
+		status.Status = domain.StatusUnknown
+	default:
+		return
+	}
+	status.LastActivity = nil
+	status.CustomStatus = nil
+	status.DNDUntil = nil
+}
+
+// validateVisibilityRules validates the rules and normalizes the viewer IDs
+func (s *UserStatusService) validateVisibilityRules(rules *domain.VisibilityRules) error {
+	if rules.Default == "" {
+		rules.Default = domain.VisibilityVisible
+	}
+	if !rules.Default.IsValid() {
+		return invalidf("invalid default visibility: %s (must be visible, offline, or hidden)", rules.Default)
+	}
+
+	if len(rules.Rules) > MaxVisibilityRules {
+		return invalidf("too many visibility rules (max %d)", MaxVisibilityRules)
+	}
+
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+
+		rule.Name = strings.TrimSpace(rule.Name)
+		if len([]rune(rule.Name)) > MaxVisibilityName {
+			return invalidf("rule name too long (max %d characters)", MaxVisibilityName)
+		}
+		if !rule.Visibility.IsValid() {
+			return invalidf("invalid visibility: %s (must be visible, offline, or hidden)", rule.Visibility)
+		}
+		if len(rule.Viewers) == 0 {
+			return invalidf("visibility rule must have at least one viewer")
+		}
+		if len(rule.Viewers) > MaxVisibilityViewers {
+			return invalidf("too many viewers in visibility rule (max %d)", MaxVisibilityViewers)
+		}
+		for j := range rule.Viewers {
+			if err := s.validateUserID(&rule.Viewers[j]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateViewerID normalizes the viewer ID; an empty viewer stays anonymous
+func (s *UserStatusService) validateViewerID(viewerID *string) error {
+	if strings.TrimSpace(*viewerID) == "" {
+		*viewerID = ""
+		return nil
+	}
+	return s.validateUserID(viewerID)
+}
@@ -31,12 +31,45 @@ func main() {
 	}
 	fmt.Println("✅ Redis connected:", pong)
 
+	// Load presence policy (TTLs, heartbeat intervals, transitions)
+	presencePolicy, err := config.NewPresencePolicy()
+	if err != nil {
+		log.Fatal("❌ Failed to load presence policy:", err)
+	}
+
+	// Load user ID scheme (prefix, uuid, snowflake or regex)
+	userIDValidator, err := services.NewUserIDValidator(config.NewUserIDScheme())
+	if err != nil {
+		log.Fatal("❌ Failed to configure user IDs:", err)
+	}
+
 	// Initialize dependencies
-	userStatusRepo := repository.NewRedisUserStatusRepository(redisClient)
-	userStatusService := services.NewUserStatusService(userStatusRepo)
+	userStatusRepo := repository.NewRedisUserStatusRepository(redisClient, presencePolicy, config.NewStatusHistoryRetention())
+	roomRepo := repository.NewRedisRoomRepository(redisClient)
+	typingRepo := repository.NewRedisTypingRepository(redisClient)
+	presenceStatsRepo := repository.NewRedisPresenceStatsRepository(redisClient)
+	contactsRepo := repository.NewRedisContactsRepository(redisClient)
+	userStatusService := services.NewUserStatusService(userStatusRepo, contactsRepo, presencePolicy, userIDValidator, config.NewBulkMaxUserIDs())
+
+	// Start background worker for status expirations
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	defer stopWorker()
+	expiryWorker := services.NewStatusExpiryWorker(userStatusRepo, roomRepo)
+	go func() {
+		fmt.Println("⏱️ Starting status expiry worker...")
+		if err := expiryWorker.Run(workerCtx); err != nil && err != context.Canceled {
+			log.Printf("❌ Status expiry worker stopped: %v", err)
+		}
+	}()
 
 	// Setup router
-	r := router.SetupRouter(userStatusService)
+	r := router.SetupRouter(router.Services{
+		UserStatus:    userStatusService,
+		Rooms:         services.NewRoomService(roomRepo, userStatusService),
+		Typing:        services.NewTypingService(typingRepo, userStatusService),
+		PresenceStats: services.NewPresenceStatsService(presenceStatsRepo),
+		Contacts:      services.NewContactsService(contactsRepo, userStatusService),
+	}, config.NewInternalAPIToken())
 
 	// Setup HTTP server
 	srv := &http.Server{
@@ -60,6 +93,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	fmt.Println("\n🛑 Shutting down server...")
+	stopWorker()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)